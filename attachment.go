@@ -0,0 +1,153 @@
+package hush
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A leaf may hold a binary attachment, like a PDF or a keystore.  The
+// content is split into chunks stored beneath
+// hush-attachments/<path>/<n> so that no single value is huge.  Each
+// chunk is encrypted separately.  The leaf itself holds the content's
+// SHA-256 digest, and its metadata records the original filename, MIME
+// type and size.  Like metadata, chunks travel with the tree and are
+// covered by its checksum.
+
+// attachmentChunkSize is the most content stored in one chunk
+const attachmentChunkSize = 48 * 1024
+
+// attachmentRoot returns the path beneath which p's chunks are stored.
+func attachmentRoot(p Path) Path {
+	return NewPath("hush-attachments/" + string(p))
+}
+
+// attachmentChunk returns the path which stores chunk n of p's
+// attachment.
+func attachmentChunk(p Path, n int) Path {
+	return NewPath(fmt.Sprintf("hush-attachments/%s/%04d", p, n))
+}
+
+// attachmentOwner returns the leaf whose attachment includes chunk
+// path c.
+func attachmentOwner(c Path) Path {
+	s := strings.TrimPrefix(string(c), "hush-attachments/")
+	return NewPath(s[:strings.LastIndex(s, "/")])
+}
+
+// IsAttachment returns true if leaf p holds a binary attachment.
+func (t *Tree) IsAttachment(p Path) bool {
+	_, ok := t.get(attachmentChunk(p, 0))
+	return ok
+}
+
+// attachmentError explains that a command which needs text can't use
+// leaf p's binary attachment.
+func attachmentError(p Path) error {
+	return fmt.Errorf("%s is a binary attachment. use 'hush detach'", p)
+}
+
+// dropAttachment removes leaf p's attachment, if any, leaving the leaf
+// itself.
+func (t *Tree) dropAttachment(p Path) {
+	t.Delete(attachmentRoot(p))
+	for _, field := range []string{"filename", "mime-type", "size"} {
+		t.Delete(metadataPath(p, field))
+	}
+}
+
+// setLeaf stores v as user leaf p's value, dropping any attachment p
+// had.  Commands which write user data use it instead of set.
+func (t *Tree) setLeaf(p Path, v *Value) {
+	t.dropAttachment(p)
+	t.set(p, v)
+}
+
+// Attach stores the content read from r as leaf p's attachment,
+// replacing any value p had.  filename and mimeType describe the
+// content.
+func (t *Tree) Attach(p Path, r io.Reader, filename, mimeType string) error {
+	t.dropAttachment(p)
+
+	hash := sha256.New()
+	var size int64
+	for n := 0; ; n++ {
+		chunk := make([]byte, attachmentChunkSize)
+		k, err := io.ReadFull(r, chunk)
+		if err == io.EOF && n > 0 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			t.dropAttachment(p)
+			return err
+		}
+		chunk = chunk[:k]
+		hash.Write(chunk)
+		size += int64(k)
+		t.set(attachmentChunk(p, n), NewPlaintext(chunk, Private))
+		if k < attachmentChunkSize {
+			break
+		}
+	}
+
+	digest := "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	t.set(p, NewPlaintext([]byte(digest), Private))
+	t.SetMetadata(p, "filename", filename)
+	t.SetMetadata(p, "mime-type", mimeType)
+	t.SetMetadata(p, "size", strconv.FormatInt(size, 10))
+	return nil
+}
+
+// Detach writes leaf p's attachment to w, one chunk at a time.  It
+// fails if the content doesn't match the digest recorded when it was
+// attached, but only after everything has been written.
+func (t *Tree) Detach(w io.Writer, p Path) error {
+	if !t.IsAttachment(p) {
+		return fmt.Errorf("no attachment at %s", p)
+	}
+	v, _ := t.get(p)
+	v, err := v.Plaintext(t.encryptionKey)
+	if err != nil {
+		return fmt.Errorf("%s: %s", p, err)
+	}
+	digest := string(v.plaintext)
+
+	hash := sha256.New()
+	for n := 0; ; n++ {
+		v, ok := t.get(attachmentChunk(p, n))
+		if !ok {
+			break
+		}
+		v, err = v.Plaintext(t.encryptionKey)
+		if err != nil {
+			return fmt.Errorf("%s: %s", attachmentChunk(p, n), err)
+		}
+		hash.Write(v.plaintext)
+		if _, err := w.Write(v.plaintext); err != nil {
+			return err
+		}
+	}
+	if digest != "sha256:"+hex.EncodeToString(hash.Sum(nil)) {
+		return errors.New("attachment is corrupt. digest doesn't match")
+	}
+	return nil
+}
+
+// attachmentSummary describes an attachment of size bytes for display
+// in place of its content.
+func attachmentSummary(size string) string {
+	n, err := strconv.ParseInt(size, 10, 64)
+	switch {
+	case err != nil:
+		return "<binary>"
+	case n < 1024:
+		return fmt.Sprintf("<binary, %d bytes>", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("<binary, %d KB>", (n+1023)/1024)
+	}
+	return fmt.Sprintf("<binary, %.1f MB>", float64(n)/(1024*1024))
+}
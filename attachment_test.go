@@ -0,0 +1,107 @@
+package hush
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestAttachmentRoundTrip(t *testing.T) {
+	tree := newTestTree()
+	content := make([]byte, 2*attachmentChunkSize+100)
+	rand.Read(content)
+
+	p := NewPath("files/keystore")
+	err := tree.Attach(p, bytes.NewReader(content), "keystore.jks", "application/octet-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tree.IsAttachment(p) {
+		t.Fatalf("%s should be an attachment", p)
+	}
+	if _, ok := tree.get(attachmentChunk(p, 2)); !ok {
+		t.Errorf("content should be split into 3 chunks")
+	}
+
+	// attachments survive encryption and moving
+	tree = tree.Encrypt()
+	to := NewPath("files/moved")
+	if err := tree.Rename(p, to); err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := tree.Detach(&got, to); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), content) {
+		t.Errorf("detached content differs from attached content")
+	}
+	if size, _ := tree.metadataValue(metadataPath(to, "size")); attachmentSummary(size) != "<binary, 97 KB>" {
+		t.Errorf("wrong summary for size %s", size)
+	}
+
+	// deleting the leaf deletes its chunks
+	tree.Delete(to)
+	for _, branch := range tree.branches {
+		if branch.path.IsAttachment() {
+			t.Errorf("chunk %s should have been deleted", branch.path)
+		}
+	}
+}
+
+func TestAttachmentNotText(t *testing.T) {
+	tree := newTestTree()
+	p := NewPath("files/keystore")
+	err := tree.Attach(p, bytes.NewReader([]byte{0, 1, 2}), "keystore.jks", "application/octet-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tree.plaintext(p); err == nil {
+		t.Errorf("plaintext of an attachment should fail")
+	}
+	var out bytes.Buffer
+	if err := CmdK8sSecret(&out, tree, "files", "files", "", false); err == nil {
+		t.Errorf("k8s-secret should refuse an attachment")
+	}
+	query := strings.NewReader(`{"path":"files"}`)
+	if err := CmdTerraformExternal(&out, query, tree); err == nil {
+		t.Errorf("terraform-external should refuse an attachment")
+	}
+	for _, text := range []string{`{{secret "files/keystore"}}`, `{{range subtree "files"}}{{.Value}}{{end}}`} {
+		tmpl := template.Must(template.New("t").Funcs(templateFuncs(tree, true)).Parse(text))
+		if err := tmpl.Execute(&out, nil); err == nil {
+			t.Errorf("template %s should refuse an attachment", text)
+		}
+	}
+	key := NewPath("aws/access-key-id")
+	tree.set(NewPath("aws/secret-access-key"), NewPlaintext([]byte("secret"), Private))
+	if err := tree.Attach(key, bytes.NewReader([]byte{0}), "key", "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CmdAwsCredentials(&out, tree, "aws"); err == nil {
+		t.Errorf("aws-credentials should refuse an attachment")
+	}
+}
+
+func TestSetLeafDropsAttachment(t *testing.T) {
+	tree := newTestTree()
+	p := NewPath("files/keystore")
+	err := tree.Attach(p, bytes.NewReader([]byte{0, 1, 2}), "keystore.jks", "application/octet-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree.setLeaf(p, NewPlaintext([]byte("text"), Private))
+	if tree.IsAttachment(p) {
+		t.Errorf("%s still has an attachment", p)
+	}
+	if _, ok := tree.get(metadataPath(p, "filename")); ok {
+		t.Errorf("%s still has a filename", p)
+	}
+	if value, err := tree.plaintext(p); err != nil || value != "text" {
+		t.Errorf("got %q, %v", value, err)
+	}
+}
@@ -0,0 +1,183 @@
+package hush
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Finding describes a problem discovered by auditing a tree.
+type Finding struct {
+	Kind   string   `json:"kind"` // reused, weak, old or expired
+	Paths  []string `json:"paths"`
+	Detail string   `json:"detail"`
+}
+
+// defaultStrengthPattern selects the leaves whose names suggest that
+// they hold passwords, like "password", "passphrase", "secret-key" or
+// "api-token".  Other values, like usernames and URLs, needn't be
+// strong.
+const defaultStrengthPattern = "path-re:(^|/)[^/]*(pass|secret|token)[^/]*$"
+
+// Audit examines every leaf in an unlocked tree for values that are
+// older than maxAge or past their expiration date.  Leaves matching
+// strengthPattern are also checked for weak or reused values.
+func (t *Tree) Audit(maxAge time.Duration, now time.Time, strengthPattern string) ([]Finding, error) {
+	checkStrength, err := compilePattern(strengthPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	t.Sort()
+
+	seen := make(map[string][]string) // value -> paths
+	var values []string
+	for _, branch := range t.branches {
+		p := branch.path
+		if !p.IsUserData() || t.IsAttachment(p) {
+			continue
+		}
+		v, err := branch.val.Plaintext(t.encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", p, err)
+		}
+		value := string(v.plaintext)
+		if checkStrength(p) {
+			if _, ok := seen[value]; !ok {
+				values = append(values, value)
+			}
+			seen[value] = append(seen[value], p.String())
+
+			if reasons := weaknesses(value); len(reasons) > 0 {
+				findings = append(findings, Finding{
+					Kind:   "weak",
+					Paths:  []string{p.String()},
+					Detail: strings.Join(reasons, ", "),
+				})
+			}
+		}
+
+		modified, ok := t.Timestamp(p, "modified")
+		if !ok {
+			modified, ok = t.Timestamp(p, "created")
+		}
+		if ok && now.Sub(modified) > maxAge {
+			days := int(now.Sub(modified).Hours() / 24)
+			findings = append(findings, Finding{
+				Kind:   "old",
+				Paths:  []string{p.String()},
+				Detail: fmt.Sprintf("last changed %d days ago", days),
+			})
+		}
+
+		if expires, ok := t.Timestamp(p, "expires"); ok && now.After(expires) {
+			findings = append(findings, Finding{
+				Kind:   "expired",
+				Paths:  []string{p.String()},
+				Detail: "expired " + expires.Format("2006-01-02"),
+			})
+		}
+	}
+
+	for _, value := range values {
+		if paths := seen[value]; len(paths) > 1 {
+			findings = append(findings, Finding{
+				Kind:   "reused",
+				Paths:  paths,
+				Detail: fmt.Sprintf("same value in %d places", len(paths)),
+			})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Kind < findings[j].Kind
+	})
+	return findings, nil
+}
+
+// weaknesses returns reasons why password is weak, if any.
+func weaknesses(password string) []string {
+	var reasons []string
+	n := len([]rune(password))
+	if n < 12 {
+		reasons = append(reasons, fmt.Sprintf("short (%d characters)", n))
+	}
+	if bits := entropy(password); bits < 60 {
+		reasons = append(reasons, fmt.Sprintf("low entropy (about %d bits)", int(bits)))
+	}
+	if isDictionaryWord(password) {
+		reasons = append(reasons, "dictionary word")
+	}
+	return reasons
+}
+
+// entropy estimates the number of bits of entropy in password,
+// assuming that its characters were chosen at random from the
+// character classes it uses.
+func entropy(password string) float64 {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if other {
+		size += 33
+	}
+	if size == 0 {
+		return 0
+	}
+	return float64(len([]rune(password))) * math.Log2(float64(size))
+}
+
+// leetspeak undoes common character substitutions
+var leetspeak = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s",
+)
+
+var dictionary map[string]bool
+
+// isDictionaryWord returns true if password is a common password or
+// word, perhaps disguised by capitalization, character substitutions
+// or a few leading and trailing digits and symbols.
+func isDictionaryWord(password string) bool {
+	if dictionary == nil {
+		dictionary = make(map[string]bool)
+		for _, word := range commonPasswords {
+			dictionary[word] = true
+		}
+		for _, word := range wordlist {
+			dictionary[word] = true
+		}
+	}
+
+	notLetter := func(r rune) bool { return !unicode.IsLetter(r) }
+	s := strings.ToLower(password)
+	l := leetspeak.Replace(s)
+	return dictionary[s] ||
+		dictionary[l] ||
+		dictionary[strings.TrimFunc(s, notLetter)] ||
+		dictionary[strings.TrimFunc(l, notLetter)]
+}
@@ -0,0 +1,65 @@
+package hush
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWeaknesses(t *testing.T) {
+	weak := []string{"secret", "P@ssw0rd", "dragon1999!", "aaaaaaaaaaaa"}
+	for _, password := range weak {
+		if len(weaknesses(password)) == 0 {
+			t.Errorf("%q should be weak", password)
+		}
+	}
+
+	strong := []string{"x7#Lq9!vR2@mT4pZ", "correct-horse-battery-staple"}
+	for _, password := range strong {
+		if reasons := weaknesses(password); len(reasons) > 0 {
+			t.Errorf("%q should be strong: %v", password, reasons)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	tree := newTestTree()
+	tree.set(NewPath("work/vpn"), NewPlaintext([]byte("secret"), Private))
+	tree.Touch(NewPath("work/vpn"))
+	tree.SetMetadata(NewPath("work/vpn"), "expires", "2001-01-01")
+
+	tree.set(NewPath("work/username"), NewPlaintext([]byte("bob"), Private))
+	tree.set(NewPath("home/username"), NewPlaintext([]byte("bob"), Private))
+
+	// work/vpn and the usernames don't look like passwords
+	later := time.Now().AddDate(1, 0, 0)
+	tests := map[string]struct{ weak, reused int }{
+		defaultStrengthPattern: {2, 0},
+		"re:":                  {5, 2},
+	}
+	for strength, test := range tests {
+		findings, err := tree.Audit(90*24*time.Hour, later, strength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds := make(map[string]int)
+		for _, f := range findings {
+			kinds[f.Kind]++
+		}
+		expect := map[string]int{"weak": test.weak, "reused": test.reused, "old": 1, "expired": 1}
+		for kind, n := range expect {
+			if kinds[kind] != n {
+				t.Errorf("%s %s: got %d findings, expected %d", strength, kind, kinds[kind], n)
+			}
+		}
+	}
+
+	match, err := compilePattern(defaultStrengthPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"a/password", "a/Passphrase", "a/secret-key", "a/api_token", "pass"} {
+		if !match(NewPath(p)) {
+			t.Errorf("%s should have its strength checked", p)
+		}
+	}
+}
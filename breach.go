@@ -0,0 +1,148 @@
+package hush
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/md4"
+)
+
+// BreachCorpus is a list of password hashes from public breaches, in
+// the format published by Have I Been Pwned.  Each line holds an
+// uppercase hexadecimal hash, a colon and the number of times that
+// password was seen.  Lines must be sorted by hash.  Lookups binary
+// search the file on disk, so even huge corpora need little memory.
+type BreachCorpus struct {
+	file *os.File
+	size int64
+	ntlm bool
+}
+
+// OpenBreachCorpus opens a corpus file.  If ntlm is true, the file
+// contains NTLM hashes.  Otherwise, it contains SHA-1 hashes.
+func OpenBreachCorpus(filename string, ntlm bool) (*BreachCorpus, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &BreachCorpus{file: file, size: stat.Size(), ntlm: ntlm}, nil
+}
+
+// Close closes the underlying corpus file.
+func (c *BreachCorpus) Close() error {
+	return c.file.Close()
+}
+
+// Hash returns the hash of password in the format used by the corpus.
+func (c *BreachCorpus) Hash(password []byte) string {
+	var sum []byte
+	if c.ntlm {
+		// NTLM is MD4 of the UTF-16LE password
+		h := md4.New()
+		for _, u := range utf16.Encode([]rune(string(password))) {
+			h.Write([]byte{byte(u), byte(u >> 8)})
+		}
+		sum = h.Sum(nil)
+	} else {
+		s := sha1.Sum(password)
+		sum = s[:]
+	}
+	return strings.ToUpper(hex.EncodeToString(sum))
+}
+
+// Count returns the number of times password was seen in breaches.
+// Zero means it wasn't found.
+func (c *BreachCorpus) Count(password []byte) (int, error) {
+	target := []byte(c.Hash(password))
+
+	lo, hi := int64(0), c.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, err := c.lineStart(mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+		line, err := c.lineAt(start)
+		if err != nil {
+			return 0, err
+		}
+
+		hash, count := line, []byte{}
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			hash, count = line[:i], line[i+1:]
+		}
+		switch bytes.Compare(bytes.ToUpper(hash), target) {
+		case -1:
+			lo = start + int64(len(line)) + 1
+		case 1:
+			hi = mid
+		case 0:
+			n, err := strconv.Atoi(string(bytes.TrimSpace(count)))
+			if err != nil {
+				return 0, errors.Wrap(err, "malformed corpus line")
+			}
+			return n, nil
+		}
+	}
+	return 0, nil
+}
+
+// lineStart returns the offset of the first line which starts at or
+// after offset.
+func (c *BreachCorpus) lineStart(offset int64) (int64, error) {
+	if offset == 0 {
+		return 0, nil
+	}
+	buf := make([]byte, 128)
+	for pos := offset - 1; pos < c.size; pos += int64(len(buf)) {
+		n, err := c.file.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return c.size, nil
+}
+
+// lineAt returns the line which starts at offset, without its line
+// ending.
+func (c *BreachCorpus) lineAt(offset int64) ([]byte, error) {
+	var line []byte
+	buf := make([]byte, 128)
+	for pos := offset; pos < c.size; pos += int64(len(buf)) {
+		n, err := c.file.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			line = append(line, buf[:i]...)
+			break
+		}
+		line = append(line, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bytes.TrimSuffix(line, []byte("\r")), nil
+}
@@ -0,0 +1,58 @@
+package hush
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBreachCorpus(t *testing.T) {
+	c := &BreachCorpus{}
+	lines := []string{"0000000000000000000000000000000000000001:7"}
+	for i, password := range []string{"password", "123456", "letmein", "hunter2"} {
+		lines = append(lines, c.Hash([]byte(password))+":"+strings.Repeat("1", i+1))
+	}
+	lines = append(lines, "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:3")
+	sort.Strings(lines)
+
+	filename := filepath.Join(t.TempDir(), "corpus.txt")
+	err := os.WriteFile(filename, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = OpenBreachCorpus(filename, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := map[string]int{
+		"password":        1,
+		"123456":          11,
+		"letmein":         111,
+		"hunter2":         1111,
+		"correct horse":   0,
+		"":                0,
+		"battery staple!": 0,
+	}
+	for password, expect := range tests {
+		got, err := c.Count([]byte(password))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expect {
+			t.Errorf("%q: got %d, expected %d", password, got, expect)
+		}
+	}
+}
+
+func TestBreachNTLM(t *testing.T) {
+	c := &BreachCorpus{ntlm: true}
+	got := c.Hash([]byte("password"))
+	expect := "8846F7EAEE8FB117AD06BDD830B7586C"
+	if got != expect {
+		t.Errorf("got %s, expected %s", got, expect)
+	}
+}
@@ -0,0 +1,250 @@
+package hush
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A clipboard holds text for the user to paste elsewhere.  hush copies
+// a secret to the clipboard and later restores whatever was there
+// before, but only if the clipboard still holds the secret.  Backends
+// which can't read the clipboard are cleared instead.
+type clipboard interface {
+	// Name identifies the backend in HUSH_CLIPBOARD
+	Name() string
+
+	// Read returns the clipboard's contents.  Returns
+	// errClipboardUnreadable if the backend can't read.
+	Read() ([]byte, error)
+
+	// Write replaces the clipboard's contents
+	Write(data []byte) error
+}
+
+var errClipboardUnreadable = errors.New("clipboard can't be read")
+
+const defaultClipboardTimeout = 45 * time.Second
+
+// newClipboard returns the clipboard backend with the given name.  If
+// name is empty, it picks a backend based on the environment.
+func newClipboard(name string) (clipboard, error) {
+	if name == "" {
+		name = os.Getenv("HUSH_CLIPBOARD")
+	}
+	if name == "" {
+		_, wl := exec.LookPath("wl-copy")
+		_, x := exec.LookPath("xclip")
+		switch {
+		case os.Getenv("WAYLAND_DISPLAY") != "" && wl == nil:
+			name = "wl-copy"
+		case os.Getenv("DISPLAY") != "" && x == nil:
+			name = "xclip"
+		default:
+			name = "osc52"
+		}
+	}
+
+	switch name {
+	case "osc52":
+		return osc52{}, nil
+	case "xclip":
+		return commandClipboard{
+			name:  "xclip",
+			read:  []string{"xclip", "-selection", "clipboard", "-out"},
+			write: []string{"xclip", "-selection", "clipboard", "-in"},
+		}, nil
+	case "wl-copy":
+		return commandClipboard{
+			name:  "wl-copy",
+			read:  []string{"wl-paste", "--no-newline"},
+			write: []string{"wl-copy"},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown clipboard %q. try osc52, xclip or wl-copy", name)
+}
+
+// clipboardTimeout returns how long secrets stay on the clipboard,
+// according to HUSH_CLIPBOARD_TIMEOUT.  Zero means forever.
+func clipboardTimeout() (time.Duration, error) {
+	s := os.Getenv("HUSH_CLIPBOARD_TIMEOUT")
+	if s == "" {
+		return defaultClipboardTimeout, nil
+	}
+	return parseClipboardTimeout(s)
+}
+
+// parseClipboardTimeout parses a timeout chosen by the user.  Zero
+// means forever.  Otherwise, it's like parseTimeout.
+func parseClipboardTimeout(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil && d == 0 {
+		return 0, nil
+	}
+	return parseTimeout(s)
+}
+
+// parseTimeout parses a positive duration like "30s".  A plain number
+// is seconds.
+func parseTimeout(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if n, nerr := strconv.Atoi(s); nerr == nil {
+		d, err = time.Duration(n)*time.Second, nil
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid clipboard timeout: %s", s)
+	}
+	return d, nil
+}
+
+// osc52 sets the clipboard with a terminal escape sequence.  It works
+// over SSH but can't read the clipboard.  Escape sequences go to tty,
+// or the controlling terminal if it's nil.
+type osc52 struct {
+	tty *os.File
+}
+
+func (osc52) Name() string {
+	return "osc52"
+}
+
+func (osc52) Read() ([]byte, error) {
+	return nil, errClipboardUnreadable
+}
+
+func (c osc52) Write(data []byte) error {
+	tty := c.tty
+	if tty == nil {
+		var err error
+		tty, err = os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer tty.Close()
+	}
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString(data) + "\a"
+	if os.Getenv("TMUX") != "" {
+		// tmux passes the sequence along when wrapped like this
+		seq = "\x1bPtmux;\x1b" + seq + "\x1b\\"
+	}
+	_, err := io.WriteString(tty, seq)
+	return err
+}
+
+// commandClipboard uses external commands to read and write the
+// clipboard.
+type commandClipboard struct {
+	name  string
+	read  []string
+	write []string
+}
+
+func (c commandClipboard) Name() string {
+	return c.name
+}
+
+func (c commandClipboard) Read() ([]byte, error) {
+	return exec.Command(c.read[0], c.read[1:]...).Output()
+}
+
+func (c commandClipboard) Write(data []byte) error {
+	cmd := exec.Command(c.write[0], c.write[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	return cmd.Run()
+}
+
+// copyToClipboard puts secret on clipboard c.  Unless timeout is zero,
+// it starts a helper process which restores the clipboard's previous
+// contents once timeout expires.
+func copyToClipboard(c clipboard, secret []byte, timeout time.Duration) error {
+	previous, _ := c.Read()
+	if err := c.Write(secret); err != nil {
+		return err
+	}
+	if timeout == 0 {
+		return nil
+	}
+
+	// secrets travel through a pipe, never the command line
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(self, "__clipboard-restore", c.Name(), timeout.String())
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if _, ok := c.(osc52); ok {
+		// the helper has no controlling terminal, so lend it ours
+		tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer tty.Close()
+		cmd.ExtraFiles = []*os.File{tty}
+	}
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(secret)
+	fmt.Fprintf(stdin, "%s\n%s\n", hex.EncodeToString(sum[:]), base64.StdEncoding.EncodeToString(previous))
+	stdin.Close()
+	return cmd.Process.Release()
+}
+
+// ClipboardRestore waits for timeout then puts the previous contents
+// back on the named clipboard, if it still holds the secret.  r
+// provides the secret's SHA-256 hash and the previous contents.  The
+// osc52 clipboard writes to the terminal inherited as file descriptor 3.
+//
+// This function implements the hidden "hush __clipboard-restore"
+func ClipboardRestore(r io.Reader, name, timeout string) error {
+	c, err := newClipboard(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := c.(osc52); ok {
+		c = osc52{tty: os.NewFile(3, "/dev/tty")}
+	}
+	d, err := parseTimeout(timeout)
+	if err != nil {
+		return err
+	}
+	return restoreClipboard(c, r, d)
+}
+
+// restoreClipboard implements ClipboardRestore for clipboard c.
+func restoreClipboard(c clipboard, r io.Reader, d time.Duration) error {
+	in := bufio.NewReader(r)
+	hash, _ := in.ReadString('\n')
+	encoded, _ := in.ReadString('\n')
+	previous, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(d)
+	current, err := c.Read()
+	if err == errClipboardUnreadable {
+		return c.Write(nil)
+	}
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(current)
+	if hex.EncodeToString(sum[:]) != strings.TrimSpace(hash) {
+		return nil // someone copied something else since
+	}
+	return c.Write(previous)
+}
@@ -0,0 +1,152 @@
+package hush
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Duration
+		ok   bool
+	}{
+		{"30", 30 * time.Second, true},
+		{"2m", 2 * time.Minute, true},
+		{"0", 0, false},
+		{"0s", 0, false},
+		{"-5", 0, false},
+		{"-1m", 0, false},
+		{"later", 0, false},
+	}
+	for _, test := range tests {
+		got, err := parseTimeout(test.s)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("parseTimeout(%q) = %s, %v", test.s, got, err)
+		}
+	}
+
+	// users may ask to keep values on the clipboard forever
+	for _, s := range []string{"0", "0s"} {
+		if d, err := parseClipboardTimeout(s); err != nil || d != 0 {
+			t.Errorf("parseClipboardTimeout(%q) = %s, %v", s, d, err)
+		}
+	}
+	if _, err := parseClipboardTimeout("-5"); err == nil {
+		t.Errorf("negative timeout should fail")
+	}
+}
+
+// fakeClipboard is a clipboard in memory.
+type fakeClipboard struct {
+	data       []byte
+	unreadable bool
+}
+
+func (c *fakeClipboard) Name() string { return "fake" }
+
+func (c *fakeClipboard) Read() ([]byte, error) {
+	if c.unreadable {
+		return nil, errClipboardUnreadable
+	}
+	return c.data, nil
+}
+
+func (c *fakeClipboard) Write(data []byte) error {
+	c.data = data
+	return nil
+}
+
+func TestClipboardRestore(t *testing.T) {
+	secret := []byte("hunter2")
+	sum := sha256.Sum256(secret)
+	helperInput := func(previous string) *strings.Reader {
+		encoded := base64.StdEncoding.EncodeToString([]byte(previous))
+		return strings.NewReader(fmt.Sprintf("%s\n%s\n", hex.EncodeToString(sum[:]), encoded))
+	}
+
+	// still holding the secret, so restore what was there before
+	c := &fakeClipboard{}
+	if err := copyToClipboard(c, secret, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.data, secret) {
+		t.Fatalf("secret wasn't copied")
+	}
+	if err := restoreClipboard(c, helperInput("before"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if string(c.data) != "before" {
+		t.Errorf("want previous contents restored, got %q", c.data)
+	}
+
+	// something else was copied since, so leave it alone
+	c = &fakeClipboard{data: []byte("newer")}
+	if err := restoreClipboard(c, helperInput("before"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if string(c.data) != "newer" {
+		t.Errorf("clobbered newer contents with %q", c.data)
+	}
+
+	// can't tell what's there, so clear it
+	c = &fakeClipboard{data: secret, unreadable: true}
+	if err := restoreClipboard(c, helperInput(""), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.data) != 0 {
+		t.Errorf("unreadable clipboard should be cleared, got %q", c.data)
+	}
+}
+
+func TestOsc52(t *testing.T) {
+	tty, err := ioutil.TempFile(t.TempDir(), "tty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tty.Close()
+	c := osc52{tty: tty}
+
+	setenv(t, "TMUX", "")
+	if err := c.Write([]byte("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, "TMUX", "/tmp/tmux-1000/default,1,0")
+	if err := c.Write([]byte("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(tty.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq := "\x1b]52;c;aHVudGVyMg==\a"
+	if want := seq + "\x1bPtmux;\x1b" + seq + "\x1b\\"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := c.Read(); err != errClipboardUnreadable {
+		t.Errorf("osc52 can't read the clipboard, got %v", err)
+	}
+}
+
+func TestNewClipboard(t *testing.T) {
+	for _, name := range []string{"osc52", "xclip", "wl-copy"} {
+		setenv(t, "HUSH_CLIPBOARD", name)
+		c, err := newClipboard("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Name() != name {
+			t.Errorf("HUSH_CLIPBOARD=%s chose %s", name, c.Name())
+		}
+	}
+	if _, err := newClipboard("pbcopy"); err == nil {
+		t.Errorf("unknown clipboards should fail")
+	}
+}
@@ -0,0 +1,83 @@
+package hush
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// CmdAttach stores the content of filename as leaf p's attachment.  If
+// filename is "-", the content is read from r.
+//
+// This function implements "hush attach"
+func CmdAttach(w io.Writer, r io.Reader, tree *Tree, p Path, filename string) error {
+	if err := checkSettable(p); err != nil {
+		return err
+	}
+	name := ""
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+		name = filepath.Base(filename)
+	}
+
+	// guess the MIME type from the name, or else the content
+	br := bufio.NewReaderSize(r, 512)
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	if mimeType == "" {
+		head, _ := br.Peek(512)
+		mimeType = http.DetectContentType(head)
+	}
+
+	if err := tree.Attach(p, br, name, mimeType); err != nil {
+		return err
+	}
+	tree.Touch(p)
+	t, err := tree.Filter(p.Parent().String())
+	if err != nil {
+		return err
+	}
+	t.Print(w)
+	return tree.Save()
+}
+
+// CmdDetach writes leaf p's attachment to the file named filename,
+// which only the user can read.  If filename is "-", it's written to
+// w instead.
+//
+// This function implements "hush detach"
+func CmdDetach(w io.Writer, tree *Tree, p Path, filename string) error {
+	if filename == "-" {
+		return tree.Detach(w, p)
+	}
+	if !tree.IsAttachment(p) {
+		return fmt.Errorf("no attachment at %s", p)
+	}
+	// write a private temporary file then move it over filename, so
+	// the content never lands in a file others can read
+	file, err := ioutil.TempFile(filepath.Dir(filename), ".hush-detach-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // in case we fail before renaming
+	err = file.Chmod(safePerm)
+	if err == nil {
+		err = tree.Detach(file, p)
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filename)
+}
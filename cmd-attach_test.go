@@ -0,0 +1,50 @@
+package hush
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetachPrivate(t *testing.T) {
+	tree := newTestTree()
+	p := NewPath("files/keystore")
+	content := []byte{0, 1, 2, 3}
+	err := tree.Attach(p, bytes.NewReader(content), "keystore.jks", "application/octet-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an existing file others could read is replaced
+	filename := filepath.Join(t.TempDir(), "keystore.jks")
+	if err := ioutil.WriteFile(filename, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CmdDetach(ioutil.Discard, tree, p, filename); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("got %v", got)
+	}
+	stat, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != safePerm {
+		t.Errorf("detached file has mode %s", stat.Mode())
+	}
+
+	// a failure leaves the existing file alone
+	if err := CmdDetach(ioutil.Discard, tree, NewPath("files/missing"), filename); err == nil {
+		t.Errorf("detaching a missing attachment should fail")
+	}
+	if _, err := os.Stat(filename); err != nil {
+		t.Errorf("existing file was removed: %s", err)
+	}
+}
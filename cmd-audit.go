@@ -0,0 +1,45 @@
+package hush
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// CmdAudit writes to w a report of problems with the values in tree.
+// Values older than maxAge days are reported.  Only leaves matching
+// strength are checked for weak or reused values.  If threshold is not
+// negative and there are more findings than that, an error is
+// returned.
+//
+// This function implements "hush audit"
+func CmdAudit(w io.Writer, tree *Tree, asJSON bool, maxAge, threshold int, strength string) error {
+	age := time.Duration(maxAge) * 24 * time.Hour
+	findings, err := tree.Audit(age, time.Now(), strength)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		if findings == nil {
+			findings = []Finding{}
+		}
+		data, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return err
+		}
+		w.Write(data)
+		io.WriteString(w, "\n")
+	} else {
+		for _, f := range findings {
+			fmt.Fprintf(w, "%-8s %s: %s\n", f.Kind, strings.Join(f.Paths, ", "), f.Detail)
+		}
+	}
+
+	if threshold >= 0 && len(findings) > threshold {
+		return fmt.Errorf("%d findings exceed threshold of %d", len(findings), threshold)
+	}
+	return nil
+}
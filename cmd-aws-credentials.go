@@ -0,0 +1,68 @@
+package hush
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// awsCredentials is the document AWS SDKs expect from a
+// credential_process.
+type awsCredentials struct {
+	Version         int
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string `json:",omitempty"`
+	Expiration      string `json:",omitempty"`
+}
+
+// CmdAwsCredentials writes to w the AWS credentials stored beneath p,
+// in the format used by credential_process.  p must have
+// access-key-id and secret-access-key leaves.  session-token and
+// expiration leaves are optional.
+//
+// This function implements "hush aws-credentials"
+func CmdAwsCredentials(w io.Writer, tree *Tree, p Path) error {
+	leaf := func(name string, required bool) (string, error) {
+		child := NewPath(p.String() + "/" + name)
+		if _, ok := tree.get(child); !ok {
+			if required {
+				return "", fmt.Errorf("missing leaf %s", child)
+			}
+			return "", nil
+		}
+		return tree.plaintext(child)
+	}
+
+	creds := awsCredentials{Version: 1}
+	var err error
+	if creds.AccessKeyId, err = leaf("access-key-id", true); err != nil {
+		return err
+	}
+	if creds.SecretAccessKey, err = leaf("secret-access-key", true); err != nil {
+		return err
+	}
+	if creds.SessionToken, err = leaf("session-token", false); err != nil {
+		return err
+	}
+	expiration, err := leaf("expiration", false)
+	if err != nil {
+		return err
+	}
+	if expiration != "" {
+		when, err := parseTimestamp(expiration)
+		if err != nil {
+			return fmt.Errorf("%s/expiration: %s", p, err)
+		}
+		creds.Expiration = when.UTC().Format(time.RFC3339)
+	}
+
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	w.Write(data)
+	io.WriteString(w, "\n")
+	return nil
+}
@@ -0,0 +1,62 @@
+package hush
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestAwsCredentials(t *testing.T) {
+	tree := newTestTree()
+	set := func(leaf, value string) {
+		tree.set(NewPath("aws/prod/"+leaf), NewPlaintext([]byte(value), Private))
+	}
+	set("access-key-id", "AKIAEXAMPLE")
+	set("secret-access-key", "wJalrXUtnFEMI")
+
+	// optional fields are left out
+	var out bytes.Buffer
+	if err := CmdAwsCredentials(&out, tree, "aws/prod"); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"Version":         1.0,
+		"AccessKeyId":     "AKIAEXAMPLE",
+		"SecretAccessKey": "wJalrXUtnFEMI",
+	}
+	if len(doc) != len(want) {
+		t.Errorf("got fields %v", doc)
+	}
+	for k, v := range want {
+		if doc[k] != v {
+			t.Errorf("%s: got %v, want %v", k, doc[k], v)
+		}
+	}
+
+	// expiration is normalized to RFC 3339 in UTC
+	set("session-token", "FwoGZXIvYXdzE")
+	set("expiration", "2030-01-02T03:04:05+02:00")
+	out.Reset()
+	if err := CmdAwsCredentials(&out, tree, "aws/prod"); err != nil {
+		t.Fatal(err)
+	}
+	var creds awsCredentials
+	if err := json.Unmarshal(out.Bytes(), &creds); err != nil {
+		t.Fatal(err)
+	}
+	if creds.SessionToken != "FwoGZXIvYXdzE" || creds.Expiration != "2030-01-02T01:04:05Z" {
+		t.Errorf("got %+v", creds)
+	}
+
+	set("expiration", "next tuesday")
+	if err := CmdAwsCredentials(&out, tree, "aws/prod"); err == nil {
+		t.Errorf("invalid expiration should fail")
+	}
+	if err := CmdAwsCredentials(&out, tree, "aws/staging"); err == nil {
+		t.Errorf("missing leaves should fail")
+	}
+}
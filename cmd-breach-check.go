@@ -0,0 +1,44 @@
+package hush
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CmdBreachCheck writes to w the paths whose values appear in the
+// breach corpus stored in file corpus.  If ntlm is true, the corpus
+// holds NTLM hashes instead of SHA-1.  Nothing is sent over the
+// network.
+//
+// This function implements "hush breach-check"
+func CmdBreachCheck(w io.Writer, tree *Tree, corpus string, ntlm bool) error {
+	if corpus == "" {
+		return errors.New("Usage: hush breach-check --corpus FILE [--ntlm]")
+	}
+	c, err := OpenBreachCorpus(corpus, ntlm)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	tree.Sort()
+	for _, branch := range tree.branches {
+		p := branch.path
+		if !p.IsUserData() || tree.IsAttachment(p) {
+			continue
+		}
+		v, err := branch.val.Plaintext(tree.encryptionKey)
+		if err != nil {
+			return fmt.Errorf("%s: %s", p, err)
+		}
+		n, err := c.Count(v.plaintext)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			fmt.Fprintf(w, "%s\t%d\n", p, n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package hush
+
+import (
+	"fmt"
+	"io"
+)
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for hush
+_hush() {
+    local IFS=$'\n'
+    COMPREPLY=($(hush __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == */ ]]; then
+        compopt -o nospace
+    fi
+}
+complete -F _hush hush
+`,
+	"zsh": `#compdef hush
+# zsh completion for hush
+_hush() {
+    local -a candidates interior
+    candidates=(${(f)"$(hush __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    interior=(${(M)candidates:#*/})
+    candidates=(${candidates:#*/})
+    compadd -S '' -- $interior
+    compadd -- $candidates
+}
+compdef _hush hush
+`,
+	"fish": `# fish completion for hush
+function __hush_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    hush __complete $tokens[2..-1] 2>/dev/null
+end
+complete -c hush -f -a '(__hush_complete)'
+`,
+}
+
+// CmdCompletion writes to w a script which teaches shell to complete
+// hush commands and paths.
+//
+// This function implements "hush completion"
+func CmdCompletion(w io.Writer, shell string) error {
+	script, ok := completionScripts[shell]
+	if !ok {
+		return fmt.Errorf("unknown shell %q. try bash, zsh or fish", shell)
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
@@ -0,0 +1,34 @@
+package hush
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// CmdCopy puts the value of the leaf at p on the clipboard.  After
+// timeout, the clipboard's previous contents are restored.  A timeout
+// of zero leaves the value on the clipboard.
+//
+// This function implements "hush copy"
+func CmdCopy(w io.Writer, tree *Tree, p Path, timeout time.Duration) error {
+	value, err := tree.plaintext(p)
+	if err != nil {
+		return err
+	}
+
+	c, err := newClipboard("")
+	if err != nil {
+		return err
+	}
+	err = copyToClipboard(c, []byte(value), timeout)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Copied %s to the clipboard", p)
+	if timeout > 0 {
+		fmt.Fprintf(w, " for %s", timeout)
+	}
+	fmt.Fprintln(w)
+	return nil
+}
@@ -0,0 +1,162 @@
+package hush
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Docker runs credential helpers named docker-credential-<name> with
+// an operation as the only argument.  See
+// https://github.com/docker/docker-credential-helpers for the protocol.
+// Credentials live beneath HUSH_DOCKER_PREFIX (default "docker") at
+// <server-url>/username and <server-url>/secret, with the server URL
+// escaped to fit in a single path component.
+
+// errDockerNotFound is the message docker expects for missing
+// credentials.
+var errDockerNotFound = errors.New("credentials not found in native keychain")
+
+// dockerCredential is the JSON representation docker uses.
+type dockerCredential struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// dockerPrefix returns the subtree where docker credentials are
+// stored.
+func dockerPrefix() string {
+	if prefix := os.Getenv("HUSH_DOCKER_PREFIX"); prefix != "" {
+		return strings.TrimSuffix(prefix, "/")
+	}
+	return "docker"
+}
+
+// dockerRoot returns the path beneath which a server's credentials
+// are stored.
+func dockerRoot(server string) Path {
+	return NewPath(dockerPrefix() + "/" + url.PathEscape(server))
+}
+
+// dockerPath returns the path of one field of a server's credentials.
+func dockerPath(server, field string) Path {
+	return NewPath(string(dockerRoot(server)) + "/" + field)
+}
+
+// DockerCredentialMain implements the main() function of the
+// docker-credential-hush helper.
+func DockerCredentialMain() {
+	if len(os.Args) != 2 {
+		die("Usage: docker-credential-hush get|store|erase|list")
+	}
+	tree, err := unlockTree()
+	if err == nil {
+		err = CmdDockerCredential(os.Stdout, os.Stdin, tree, os.Args[1])
+	}
+	if err != nil {
+		// docker reads error messages from stdout
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
+// CmdDockerCredential performs one operation of docker's credential
+// helper protocol: get, store, erase or list.  Input comes from r and
+// output goes to w.
+func CmdDockerCredential(w io.Writer, r io.Reader, tree *Tree, op string) error {
+	switch op {
+	case "get":
+		server, err := readServerURL(r)
+		if err != nil {
+			return err
+		}
+		cred := dockerCredential{ServerURL: server}
+		cred.Username, err = dockerField(tree, server, "username")
+		if err != nil {
+			return err
+		}
+		cred.Secret, err = dockerField(tree, server, "secret")
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(w).Encode(cred)
+	case "store":
+		var cred dockerCredential
+		if err := json.NewDecoder(r).Decode(&cred); err != nil {
+			return err
+		}
+		if cred.ServerURL == "" {
+			return errors.New("no server URL")
+		}
+		for field, value := range map[string]string{"username": cred.Username, "secret": cred.Secret} {
+			p := dockerPath(cred.ServerURL, field)
+			if err := checkSettable(p); err != nil {
+				return err
+			}
+			tree.setLeaf(p, NewPlaintext([]byte(value), Private))
+			tree.Touch(p)
+		}
+		return tree.Save()
+	case "erase":
+		server, err := readServerURL(r)
+		if err != nil {
+			return err
+		}
+		if err := checkSettable(dockerRoot(server)); err != nil {
+			return err
+		}
+		if tree.Delete(dockerRoot(server)) == 0 {
+			return errDockerNotFound
+		}
+		return tree.Save()
+	case "list":
+		servers := make(map[string]string)
+		prefix := dockerPrefix() + "/"
+		for _, branch := range tree.branches {
+			s := string(branch.path)
+			if !strings.HasPrefix(s, prefix) || !strings.HasSuffix(s, "/username") {
+				continue
+			}
+			escaped := strings.TrimSuffix(strings.TrimPrefix(s, prefix), "/username")
+			server, err := url.PathUnescape(escaped)
+			if err != nil || strings.Contains(escaped, "/") {
+				continue
+			}
+			servers[server], err = dockerField(tree, server, "username")
+			if err != nil {
+				return err
+			}
+		}
+		return json.NewEncoder(w).Encode(servers)
+	}
+	return fmt.Errorf("unknown operation: %s", op)
+}
+
+// readServerURL reads the server URL docker sends for get and erase.
+func readServerURL(r io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	server := strings.TrimSpace(string(data))
+	if server == "" {
+		return "", errors.New("no server URL")
+	}
+	return server, nil
+}
+
+// dockerField returns the plaintext of one field of a server's
+// credentials.
+func dockerField(tree *Tree, server, field string) (string, error) {
+	p := dockerPath(server, field)
+	if _, ok := tree.get(p); !ok {
+		return "", errDockerNotFound
+	}
+	return tree.plaintext(p)
+}
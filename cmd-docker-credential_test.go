@@ -0,0 +1,28 @@
+package hush
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDockerCredentialConfigurationPrefix(t *testing.T) {
+	useTempHushFile(t)
+	setenv(t, "HUSH_DOCKER_PREFIX", "hush-configuration")
+	tree := newTestTree()
+	salt := NewPath("hush-configuration/salt")
+	tree.set(salt, NewPlaintext([]byte("salt"), Public))
+
+	var out bytes.Buffer
+	store := strings.NewReader(`{"ServerURL":"salt","Username":"u","Secret":"s"}`)
+	if err := CmdDockerCredential(&out, store, tree, "store"); err == nil {
+		t.Errorf("stored credentials in the configuration")
+	}
+	erase := strings.NewReader("salt")
+	if err := CmdDockerCredential(&out, erase, tree, "erase"); err == nil {
+		t.Errorf("erased credentials from the configuration")
+	}
+	if _, ok := tree.get(salt); !ok {
+		t.Errorf("configuration was changed")
+	}
+}
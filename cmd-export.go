@@ -9,8 +9,8 @@ import "io"
 func CmdExport(w io.Writer, t *Tree) error {
 	for _, branch := range t.branches {
 		p, v := branch.path, branch.val
-		if p.IsConfiguration() || p.IsChecksum() {
-			continue
+		if !p.IsUserData() || t.IsAttachment(p) {
+			continue // attachments don't fit this format
 		}
 		v, err := v.Plaintext(t.encryptionKey)
 		if err != nil {
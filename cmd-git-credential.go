@@ -0,0 +1,157 @@
+package hush
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultGitCredentialLayout is where git credentials are stored unless
+// HUSH_GIT_CREDENTIAL_PATH says otherwise.  Placeholders in braces are
+// replaced with the attributes git sends.
+const defaultGitCredentialLayout = "git/{host}/{username}/password"
+
+var gitPlaceholder = regexp.MustCompile(`\{(protocol|host|path|username)\}`)
+
+// gitCredentialLayout returns the path layout for git credentials.
+func gitCredentialLayout() string {
+	if layout := os.Getenv("HUSH_GIT_CREDENTIAL_PATH"); layout != "" {
+		return layout
+	}
+	return defaultGitCredentialLayout
+}
+
+// readGitCredential parses git's credential helper input: lines of
+// key=value ending with a blank line or end of input.
+func readGitCredential(r io.Reader) (map[string]string, error) {
+	attrs := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("malformed credential line: %q", line)
+		}
+		attrs[line[:i]] = line[i+1:]
+	}
+	return attrs, scanner.Err()
+}
+
+// gitCredentialPath fills in layout with attrs.  Returns false if any
+// placeholder has no value.
+func gitCredentialPath(layout string, attrs map[string]string) (Path, bool) {
+	ok := true
+	s := gitPlaceholder.ReplaceAllStringFunc(layout, func(m string) string {
+		v := attrs[m[1:len(m)-1]]
+		if v == "" {
+			ok = false
+		}
+		return v
+	})
+	return NewPath(s), ok
+}
+
+// findGitCredential returns the first leaf matching layout and the
+// known attributes in attrs.  It also returns the attributes taken
+// from the leaf's path, which fill in those git didn't send.
+func findGitCredential(tree *Tree, layout string, attrs map[string]string) (Path, map[string]string, bool) {
+	// build a regular expression with a group for each placeholder
+	var names []string
+	expr := "^"
+	last := 0
+	for _, m := range gitPlaceholder.FindAllStringSubmatchIndex(layout, -1) {
+		expr += regexp.QuoteMeta(layout[last:m[0]])
+		name := layout[m[2]:m[3]]
+		names = append(names, name)
+		if name == "path" {
+			expr += "(.*)"
+		} else {
+			expr += "([^/]+)"
+		}
+		last = m[1]
+	}
+	expr += regexp.QuoteMeta(layout[last:]) + "$"
+	re := regexp.MustCompile(expr)
+
+	tree.Sort()
+	for _, branch := range tree.branches {
+		p := branch.path
+		if !p.IsUserData() {
+			continue
+		}
+		m := re.FindStringSubmatch(p.String())
+		if m == nil {
+			continue
+		}
+		found := make(map[string]string)
+		matched := true
+		for i, name := range names {
+			if attrs[name] != "" && attrs[name] != m[i+1] {
+				matched = false
+			}
+			found[name] = m[i+1]
+		}
+		if matched {
+			return p, found, true
+		}
+	}
+	return "", nil, false
+}
+
+// CmdGitCredential implements git's credential helper protocol.  op is
+// get, store or erase.  Attributes come from r and answers go to w.
+// Credentials are stored according to HUSH_GIT_CREDENTIAL_PATH.
+//
+// This function implements "hush git-credential"
+func CmdGitCredential(w io.Writer, r io.Reader, tree *Tree, op string) error {
+	attrs, err := readGitCredential(r)
+	if err != nil {
+		return err
+	}
+	layout := gitCredentialLayout()
+
+	switch op {
+	case "get":
+		p, found, ok := findGitCredential(tree, layout, attrs)
+		if !ok {
+			return nil // git tries other helpers or asks the user
+		}
+		password, err := tree.plaintext(p)
+		if err != nil {
+			return err
+		}
+		if attrs["username"] == "" && found["username"] != "" {
+			fmt.Fprintf(w, "username=%s\n", found["username"])
+		}
+		fmt.Fprintf(w, "password=%s\n", password)
+		return nil
+	case "store":
+		p, ok := gitCredentialPath(layout, attrs)
+		if !ok || attrs["password"] == "" {
+			return nil // not enough to store
+		}
+		if err := checkSettable(p); err != nil {
+			return err
+		}
+		if tree.IsAttachment(p) {
+			return attachmentError(p)
+		}
+		tree.setLeaf(p, NewPlaintext([]byte(attrs["password"]), Private))
+		tree.Touch(p)
+		return tree.Save()
+	case "erase":
+		p, _, ok := findGitCredential(tree, layout, attrs)
+		if !ok {
+			return nil
+		}
+		tree.Delete(p)
+		return tree.Save()
+	}
+	return fmt.Errorf("unknown git credential operation: %s", op)
+}
@@ -0,0 +1,82 @@
+package hush
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadGitCredential(t *testing.T) {
+	input := "protocol=https\nhost=github.com\npassword=a=b\n\nignored=after blank\n"
+	attrs, err := readGitCredential(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"protocol": "https", "host": "github.com", "password": "a=b"}
+	if len(attrs) != len(want) {
+		t.Errorf("got %q", attrs)
+	}
+	for k, v := range want {
+		if attrs[k] != v {
+			t.Errorf("%s: got %q, want %q", k, attrs[k], v)
+		}
+	}
+
+	if _, err := readGitCredential(strings.NewReader("no equals sign\n")); err == nil {
+		t.Errorf("malformed line should fail")
+	}
+}
+
+func TestGitCredentialPath(t *testing.T) {
+	attrs := map[string]string{"protocol": "https", "host": "github.com", "username": "bob"}
+	p, ok := gitCredentialPath(defaultGitCredentialLayout, attrs)
+	if !ok || p != NewPath("git/github.com/bob/password") {
+		t.Errorf("got %q, %v", p, ok)
+	}
+	delete(attrs, "username")
+	if _, ok := gitCredentialPath(defaultGitCredentialLayout, attrs); ok {
+		t.Errorf("missing username should leave the path incomplete")
+	}
+}
+
+func TestGitCredential(t *testing.T) {
+	useTempHushFile(t)
+	setenv(t, "HUSH_GIT_CREDENTIAL_PATH", "")
+	tree := newTestTree()
+	run := func(op, input string) string {
+		var out bytes.Buffer
+		if err := CmdGitCredential(&out, strings.NewReader(input), tree, op); err != nil {
+			t.Fatalf("%s: %s", op, err)
+		}
+		return out.String()
+	}
+
+	run("store", "protocol=https\nhost=github.com\nusername=bob\npassword=hunter2\n")
+	if got := run("get", "protocol=https\nhost=github.com\n"); got != "username=bob\npassword=hunter2\n" {
+		t.Errorf("get without username: %q", got)
+	}
+	if got := run("get", "protocol=https\nhost=github.com\nusername=bob\n"); got != "password=hunter2\n" {
+		t.Errorf("get with username: %q", got)
+	}
+	if got := run("get", "protocol=https\nhost=github.com\nusername=alice\n"); got != "" {
+		t.Errorf("other user's credential: %q", got)
+	}
+	if got := run("get", "protocol=https\nhost=gitlab.com\n"); got != "" {
+		t.Errorf("other host's credential: %q", got)
+	}
+
+	run("erase", "protocol=https\nhost=github.com\nusername=bob\n")
+	if got := run("get", "protocol=https\nhost=github.com\n"); got != "" {
+		t.Errorf("erased credential: %q", got)
+	}
+
+	// a custom layout may include the repository's path
+	setenv(t, "HUSH_GIT_CREDENTIAL_PATH", "code/{host}/{path}/token")
+	run("store", "protocol=https\nhost=example.com\npath=team/repo.git\npassword=t0ken\n")
+	if _, ok := tree.get(NewPath("code/example.com/team/repo.git/token")); !ok {
+		t.Errorf("custom layout wasn't used")
+	}
+	if got := run("get", "protocol=https\nhost=example.com\npath=team/repo.git\n"); got != "password=t0ken\n" {
+		t.Errorf("get with custom layout: %q", got)
+	}
+}
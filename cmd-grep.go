@@ -0,0 +1,77 @@
+package hush
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CmdGrep writes to w the paths matching pathPattern whose values
+// match pattern.  pattern is a substring, or a regular expression when
+// prefixed with "re:", and ignores case unless it contains uppercase
+// letters.  If fixed is true, values must equal pattern exactly and are
+// compared in constant time.  Values are masked unless show is true.
+//
+// This function implements "hush grep"
+func CmdGrep(w io.Writer, tree *Tree, pattern, pathPattern string, show, fixed bool) error {
+	match, err := valueMatcher(pattern, fixed)
+	if err != nil {
+		return err
+	}
+	tree, err = tree.Filter(pathPattern)
+	if err != nil {
+		return err
+	}
+
+	tree.Sort()
+	for _, branch := range tree.branches {
+		p := branch.path
+		if !p.IsUserData() || tree.IsAttachment(p) {
+			continue
+		}
+		v, err := branch.val.Plaintext(tree.encryptionKey)
+		if err != nil {
+			return fmt.Errorf("%s: %s", p, err)
+		}
+		if !match(v.plaintext) {
+			continue
+		}
+		value := "********"
+		if show {
+			value = string(v.plaintext)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", p, value)
+	}
+	return nil
+}
+
+// valueMatcher returns a function which reports whether a plaintext
+// value matches pattern.
+func valueMatcher(pattern string, fixed bool) (func([]byte) bool, error) {
+	if fixed {
+		// compare digests so that timing reveals neither the value's
+		// length nor how much of it matches
+		needle := sha256.Sum256([]byte(pattern))
+		return func(value []byte) bool {
+			digest := sha256.Sum256(value)
+			return subtle.ConstantTimeCompare(digest[:], needle[:]) == 1
+		}, nil
+	}
+	if strings.HasPrefix(pattern, "re:") {
+		re, err := compileSmartcase(strings.TrimPrefix(pattern, "re:"))
+		if err != nil {
+			return nil, err
+		}
+		return re.Match, nil
+	}
+	ignoreCase := isLowercase(pattern)
+	return func(value []byte) bool {
+		haystack := string(value)
+		if ignoreCase {
+			haystack = strings.ToLower(haystack)
+		}
+		return strings.Contains(haystack, pattern)
+	}, nil
+}
@@ -0,0 +1,36 @@
+package hush
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	tree := newTestTree()
+	tree.set(NewPath("example.com/password"), NewPlaintext([]byte("re:secret"), Private))
+
+	tests := []struct {
+		pattern     string
+		show, fixed bool
+		want        string
+	}{
+		{"secret", false, false, "example.com/password\t********\npaypal.com/personal/password\t********\n"},
+		{"BITCOINS", true, false, ""},
+		{"bitcoins", true, false, "bitpay.com/work/password\t42 bitcoins\n"},
+		{"re:^[0-9]+ bit", true, false, "bitpay.com/work/password\t42 bitcoins\n"},
+		{"secret", true, true, "paypal.com/personal/password\tsecret\n"},
+		{"SECRET", true, true, ""},
+		{"secre", true, true, ""},
+		{"re:secret", false, true, "example.com/password\t********\n"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		err := CmdGrep(&out, tree, test.pattern, "", test.show, test.fixed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != test.want {
+			t.Errorf("grep %q show=%v fixed=%v:\ngot  %q\nwant %q", test.pattern, test.show, test.fixed, got, test.want)
+		}
+	}
+}
@@ -13,7 +13,7 @@ var helpMessage = `NAME
     hush - tiny password manager
 
 SYNOPSIS
-    hush [command [arguments]]
+    hush [--keyfile file] [command [arguments]]
 
 INSTALLATION
     go install github.com/mndrix/hush/...
@@ -34,45 +34,462 @@ COMMANDS
     command name should be the second argument on the command line when
     invoking hush.
 
+    attach path file
+        Stores the content of 'file', which may be binary, in the leaf
+        at 'path'.  If file is '-', the content is read from stdin.
+        Large files are split into encrypted chunks.  The original
+        filename, MIME type and size are kept as metadata, and ls
+        shows the leaf as '<binary, 12 KB>' instead of its content.
+        Setting the leaf with the set command discards the
+        attachment.
+
+        See also: detach command
+
+    audit [--json] [--max-age days] [--threshold n] [--strength pattern]
+        Reports problems with the values in your hush file:
+
+            reused   the same value is stored at several paths
+            weak     short, low entropy or a common password
+            old      not changed for more than 'days' (default 90)
+            expired  past the date in its 'expires' metadata
+
+        Only leaves matching 'pattern' are checked for weak or reused
+        values.  By default, those are leaves whose names contain
+        'pass', 'secret' or 'token', so usernames and URLs aren't
+        reported.
+        Use --strength 're:' to check every leaf.
+
+        Ages come from each leaf's metadata.  With --json, the report
+        is written as JSON.  With --threshold, hush exits with an
+        error if there are more than 'n' findings, which is handy in
+        continuous integration.
+
+        See also: meta command
+
+    aws-credentials path
+        Prints the AWS credentials stored beneath 'path' as the JSON
+        document AWS SDKs expect from a credential_process.  'path'
+        must have 'access-key-id' and 'secret-access-key' leaves.
+        'session-token' and 'expiration' leaves are optional.  For
+        example, in ~/.aws/config:
+
+            [profile work]
+            credential_process = hush aws-credentials aws/work
+
+    breach-check --corpus file [--ntlm]
+        Reports values which appear in a list of passwords from
+        public breaches.  'file' uses the format published by Have I
+        Been Pwned: one uppercase SHA-1 hash per line, followed by a
+        colon and the number of times it was seen, sorted by hash.
+        With --ntlm, the file holds NTLM hashes instead.  The file is
+        searched in place, so it needn't fit in memory, and nothing
+        is sent over the network.
+
+        Each line of output is a path and the number of times its
+        value was seen in breaches.
+
+        See also: audit command
+
+    completion bash|zsh|fish
+        Prints a script which teaches your shell to complete hush
+        commands and paths.  Paths are stored in plaintext, so
+        completion never asks for a password.  For example, add this
+        to ~/.bashrc:
+
+            source <(hush completion bash)
+
+    copy [--timeout duration] path
+        Puts the value of the leaf at 'path' on the clipboard, so it
+        never appears on screen.  After 'duration' (default 45s),
+        the clipboard's previous contents are restored, unless
+        something else has been copied in the meantime.  A duration
+        of 0 leaves the value on the clipboard.
+
+        The clipboard is set with wl-copy under Wayland or xclip
+        under X11, if installed.  Otherwise, hush sends your terminal
+        an OSC 52 escape sequence, which also works over SSH.  OSC 52
+        can't read the clipboard, so it's cleared instead of
+        restored.  See HUSH_CLIPBOARD.
+
+    detach path file
+        Writes the attachment stored at 'path' to 'file', which only
+        you can read.  If file is '-', writes to stdout instead.  hush
+        verifies the content against a digest taken when it was
+        attached.
+
+        See also: attach command
+
     export
         Exports the decrypted contents of your hush file to stdout.
         Each line represents a leaf and the path to that leaf. Each
         line is split into two columns, separated by a tab character.
         The first column is a slash-separated path. The second column
-        is the leaf's plaintext.
+        is the leaf's plaintext.  Attachments are skipped.
 
         See also: import command
 
+    git-credential get|store|erase
+        Implements git's credential helper protocol, so git can keep
+        repository passwords in your hush file.  Configure git with:
+
+            git config --global credential.helper '!hush git-credential'
+
+        Credentials are stored at 'git/{host}/{username}/password'
+        unless HUSH_GIT_CREDENTIAL_PATH gives another layout.  When
+        git doesn't know the username, the first matching path
+        supplies it.  'store' and 'erase' save the hush file.
+
+    grep [--show] [--fixed] pattern [path-pattern]
+        Lists the paths whose values contain 'pattern'.  Like path
+        patterns, it ignores case unless it contains an uppercase
+        letter, and a 're:' prefix makes it a Go regular expression.
+        Only paths matching 'path-pattern' are searched.  Values are
+        masked unless --show is given.
+
+        With --fixed, values must equal 'pattern' exactly and are
+        compared in constant time.  Case matters and a 're:' prefix
+        has no special meaning.
+
+        See also: PATTERNS
+
     help
         Displays this help text.
 
-    import
+    import [--format=tsv|k8s-secret] [--path path]
         Imports plaintext paths and leaves from stdin into your hush
-        file.  The input format is the same as that generated by
-        the export command.
+        file.  The default input format is the same as that generated
+        by the export command.
 
-        See also: export command
+        With --format=k8s-secret, the input is a Kubernetes Secret
+        manifest.  Each key becomes a leaf beneath 'path', or beneath
+        the Secret's name if --path is omitted.
 
-    init
+        See also: export command, k8s-secret command
+
+    init [--keyfile file] [--no-password]
         Initializes a new hush file after prompting the user to
         create a password.  This command must be run before most of
         the other commands can be run.
 
-    ls [pattern]
+        With --keyfile, the hush file can only be unlocked with both
+        the password and the key file.  If the key file doesn't exist,
+        it's created with random content.  With --no-password too, the
+        key file alone unlocks the hush file.  That's handy for
+        servers and CI jobs which have no terminal.
+
+    k8s-secret pattern --name name [--namespace namespace] [--string-data]
+        Prints a Kubernetes Secret manifest holding the leaves which
+        match 'pattern'.  Each key is the last component of a leaf's
+        path, so 'db/prod/password' becomes 'password'.  Values are
+        base64 encoded in the Secret's data, or left as plain strings
+        in stringData with --string-data.  For example:
+
+            hush k8s-secret db/prod --name db | kubectl apply -f -
+
+        See also: PATTERNS, import command
+
+    keyfile add file [--no-password]
+        Requires a key file to unlock your hush file.  You're prompted
+        for a master password to use along with it, unless
+        --no-password is given.  If the key file doesn't exist, it's
+        created with random content.
+
+    keyfile rm
+        Stops requiring a key file to unlock your hush file.  You're
+        prompted for a new master password.
+
+        Both require unlocking with the master password, so they fail
+        when HUSH_IDENTITY is set or when you unlock with a
+        recipient's password.
+
+    ls [--long] [pattern]
         Lists all decrypted subtrees matching 'pattern'.  If 'pattern'
-        is omitted, lists the entire tree.
+        is omitted, lists the entire tree.  With --long (or -l), each
+        leaf's metadata is listed too.
 
         See also: PATTERNS
 
+    materialize template --fifo path
+        Creates a named pipe at 'path' which only you can use.  Each
+        time a program reads the pipe, hush renders 'template' and
+        writes the result, so secrets never touch the disk.  This
+        suits tools like curl which only read credentials from files.
+        hush keeps serving until interrupted, then removes the pipe.
+
+        Templates are the same as for the template command.
+        {{ secret "a/b" }} inserts the value at 'a/b'.  A missing path
+        is an error.  hush checks for them before creating the pipe.
+        If rendering fails later, hush reports it, removes the pipe
+        and exits, so later readers fail rather than read an empty
+        file.  For example, a template for ~/.netrc:
+
+            machine example.com
+            login {{ secret "example.com/username" }}
+            password {{ secret "example.com/password" }}
+
+    meta path [field value]
+        Displays the metadata of the leaf at 'path'.  If 'field' and
+        'value' are given, sets that metadata field instead.  An empty
+        value removes the field.  These fields can be set:
+
+            expires  date after which the value should be replaced
+            tags     any labels you like
+            notes    free-form text
+
+        hush maintains the 'created', 'modified' and 'modified-by'
+        fields itself.  The author comes from HUSH_AUTHOR or USER.
+        Attachments also have 'filename', 'mime-type' and 'size'
+        fields (see attach command).
+
+        Metadata is encrypted, except for timestamps when they're
+        made public (see timestamps command).  Older versions of hush
+        display metadata as ordinary leaves under 'hush-metadata'.
+
+    pick [--copy [--timeout duration]] [query]
+        Lets you choose a leaf by typing part of its path.  Paths are
+        matched fuzzily: the characters of the query must appear in
+        order, but not necessarily together.  Matches at the start of
+        a path component or word rank higher.  Use the arrow keys to
+        move through the list and Enter to print the chosen leaf's
+        value, or copy it with --copy (see copy command).  Escape
+        cancels.
+
+        If stdout isn't a terminal, prints the paths which match
+        'query', best match first.
+
+    recipient add name [public-key]
+        Lets another person unlock your hush file.  Each recipient
+        gets their own copy of the file's keys, so the master password
+        need not be shared.  If 'public-key' is given, it should be an
+        age public key (age1...) or an SSH ed25519 public key.  The
+        recipient unlocks the file by pointing HUSH_IDENTITY at the
+        matching private key.  Otherwise, hush prompts for a password
+        which the recipient uses instead of the master password.
+
+    recipient ls
+        Lists all recipients and their public keys.
+
+    recipient rm name
+        Removes a recipient.  The file's keys are rotated so that the
+        removed recipient can't read values written afterwards.
+        Rotating keys requires unlocking with the master password, so
+        this fails when HUSH_IDENTITY is set or when you unlock with a
+        recipient's password.
+
+    recovery split [-n shares] [-k needed]
+        Splits your hush file's keys into 'shares' recovery shares
+        (default 5), any 'needed' of which (default 3) can restore
+        access if the master password is forgotten.  Fewer shares
+        reveal nothing about the keys.  Each share is printed as a list
+        of words.  Give them to people you trust, or store them in
+        separate safe places.
+
+    recovery restore
+        Prompts for recovery shares and reconstructs your hush file's
+        keys from them.  Only the first four letters of each word are
+        needed.  A share may span several lines.  It ends with its
+        last word or at a blank line, so a share missing words is
+        reported rather than merged with the next one.  A mistyped
+        share is detected by its checksum.  After
+        the keys are verified, hush prompts for a new master password.
+        Any key file requirement is removed.
+
     rm path [path [path [...]]]
         Removes each path, and its subtrees, from the hush file.
 
+    serve [--listen address|unix:path] [--read-only]
+        Serves your hush file over HTTP for tools which only know how
+        to fetch secrets from HashiCorp Vault.  The API looks like a
+        KV version 2 engine mounted at 'secret/'.  The leaves directly
+        beneath a path make up a Vault secret, with their names as
+        keys.  A single leaf is a secret with the key 'value'.  So
+        'vault kv get secret/db/prod' reads 'db/prod/password' and
+        its siblings.
+
+        hush listens on 127.0.0.1:8200 by default, or on a Unix
+        socket which only you can use.  At startup, it prints
+        VAULT_ADDR and a random VAULT_TOKEN which clients must
+        present.  Clients can write and delete secrets unless
+        --read-only is given.  Each request is logged to stderr, with
+        paths but never values.
+
     set path value
         Sets the leaf at 'path' to have 'value'.  The value is stored
         encrypted in the hush file.  The path is not encrypted.
 
         If value is '-' then the leaf's value is read from stdin.
 
+    shell
+        Starts an interactive session which unlocks your hush file
+        once and then runs many commands: cd, get, ls, rm, set, save
+        and quit.  'cd' sets a path prefix for the other commands.
+        Tab completes commands and paths.  'set path' prompts for the
+        value so values never appear in the command history.
+
+        Changes are written only when you 'save'.  hush warns before
+        quitting with unsaved changes.
+
+    signer add name public-key
+        Trusts the holder of an SSH ed25519 key to sign hush files.
+        Once you trust any signers, hush signs your file with the key
+        in HUSH_SIGNING_KEY every time it's saved and refuses to load
+        it unless it's signed by one of the signers, even if it's not
+        signed at all.  Before adding any signers, save your file
+        once with HUSH_SIGNING_KEY set so that it's already signed.
+
+        Signers are listed in ~/.hush-signers, not in the hush file,
+        so nobody who can write the hush file can change whom you
+        trust.
+
+    signer ls
+        Lists all trusted signers and their public keys.
+
+    signer rm name
+        Stops trusting a signer.
+
+    ssh-agent [--prefix path] [--socket path] [--foreground]
+        Runs an SSH agent with the private keys stored in your hush
+        file, so keys never touch the disk.  Each key has its own
+        subtree beneath 'prefix' ('ssh' by default) with these leaves:
+
+            private-key  a PEM or OpenSSH private key (required)
+            passphrase   the key's passphrase, if it has one
+            confirm      'true' to ask before each use of the key
+            lifetime     how long the agent keeps the key, like '1h'
+
+        The agent listens on 'socket', or in a private temporary
+        directory.  Like ssh-agent, it detaches from the terminal and
+        prints commands which set SSH_AUTH_SOCK and SSH_AGENT_PID.
+        For example:
+
+            hush set ssh/github/private-key - < ~/.ssh/id_ed25519
+            eval $(hush ssh-agent)
+            ssh -T git@github.com
+            kill $SSH_AGENT_PID
+
+        With --foreground, the agent stays attached to the terminal
+        until it's interrupted.
+
+        Confirmation uses SSH_ASKPASS if it's set.  Otherwise, only an
+        agent running in the foreground can ask on its terminal.
+        Without SSH_ASKPASS, a detached agent refuses to load keys
+        which need confirmation, including those added with 'ssh-add
+        -c'.  Keys added with 'ssh-add' stay in memory only.
+
+    template [--check] [-o file] template
+        Renders 'template' with values from your hush file and prints
+        the result, or writes it to 'file' which only you can read.
+        Only the values the template refers to are decrypted.  With
+        --check, hush verifies that every path the template refers to
+        exists without decrypting anything or asking for a password.
+
+        Templates use Go's text/template syntax with these functions:
+
+            secret "a/b"   the value at 'a/b'.  a missing path is an
+                           error
+            subtree "a"    the leaves beneath 'a', for use with range.
+                           each has .Name (relative to 'a'), .Path and
+                           .Value
+            json           quote a value as a JSON string
+            yaml           quote a value as a YAML scalar
+            shell          quote a value for POSIX shells
+            url            escape a value for a URL query
+
+        For example:
+
+            export DB_PASSWORD={{ secret "db/prod/password" | shell }}
+            {{ range subtree "db/prod" -}}
+            {{ .Name }}: {{ .Value | yaml }}
+            {{ end -}}
+
+    terraform-external
+        Implements the protocol of Terraform's external data source.
+        Reads a query like {"path": "db/prod/password"} from stdin.
+        If the path is a leaf, prints {"value": "..."}.  Otherwise,
+        prints every leaf beneath the path, keyed by its path relative
+        to the query.  Errors go to stderr.  For example:
+
+            data "external" "db" {
+              program = ["hush", "terraform-external"]
+              query   = { path = "db/prod" }
+            }
+
+        Then use data.external.db.result["password"].  Terraform has
+        no terminal, so unlock with HUSH_ASKPASS, HUSH_IDENTITY or a
+        key file.
+
+    timestamps public|private
+        Chooses whether metadata timestamps are stored in plaintext
+        or encrypted.  They're encrypted by default.  Public timestamps
+        let anyone see when values changed.
+
+        Older versions of hush fail to load a file with public
+        timestamps.  Run 'hush timestamps private' before anyone uses
+        an older version with your file.
+
+    tui [--lock-after duration]
+        Opens a full-screen interface for browsing and editing your
+        hush file.  The tree is on the left and details of the
+        selected leaf are on the right.  Values stay masked until
+        revealed.  These keys are available:
+
+            up, down, j, k  select a leaf
+            /               search paths fuzzily (see pick command)
+            r, Enter        reveal or hide the value
+            c               copy the value (see copy command)
+            a               add a leaf.  an empty value is generated
+            m               rename (move) the leaf
+            d               delete the leaf
+            g               replace the value with a generated one
+            s               save all changes
+            q               quit
+
+        Changes aren't written until you save them, all at once.
+        After 'duration' (default 5m) without a key press, the keys
+        are wiped from memory and you must unlock the file again.  A
+        duration of 0 never locks.
+
+    verify
+        Verifies the hush file's checksum and signature, then reports
+        which signer, if any, last saved it.
+
+DOCKER CREDENTIALS
+
+    hush includes a second program, docker-credential-hush, which
+    lets docker keep registry credentials in your hush file instead
+    of ~/.docker/config.json.  Install it somewhere on your PATH and
+    add this to ~/.docker/config.json:
+
+        { "credsStore": "hush" }
+
+    Credentials are stored beneath 'docker/<server-url>/', with the
+    server URL escaped to fit one level of the tree.  Set
+    HUSH_DOCKER_PREFIX to use another subtree.
+
+PLUGINS
+
+    If hush doesn't know a command, it runs a program named 'hush-'
+    followed by the command name from your PATH, like git does.  For
+    example, 'hush foo bar' runs 'hush-foo bar'.  The plugin inherits
+    the environment, with HUSH_FILE set to the hush file's absolute
+    path.
+
+    Plugins needn't decrypt the hush file themselves.  HUSH_SESSION
+    names two file descriptors, '3,4'.  The plugin writes requests
+    to the second and reads responses from the first.  Requests and
+    responses are JSON objects, one per line:
+
+        {"op":"ls","pattern":"pay"}    {"paths":["paypal.com/password"]}
+        {"op":"get","path":"a/b"}      {"value":"secret"}
+        {"op":"set","path":"a/b","value":"new"}    {}
+        {"op":"rm","path":"a/b"}       {"removed":1}
+        {"op":"save"}                  {}
+
+    Failed requests get a response like {"error":"no such leaf: a/b"}.
+    hush asks for your password the first time a request needs it.
+    Listing paths never does.  Changes are written only on "save".
+    The session ends when the plugin exits.
+
 PATTERNS
 
     A pattern matches paths within the tree.  A pattern is first split
@@ -101,6 +518,28 @@ PATTERNS
             work:
                 password: 42 bitcoins
 
+    A prefix on the pattern selects a different syntax:
+
+        re:       Each subpattern is a Go regular expression which
+                  must match part of the path component at that
+                  level.  For example, 're:^prod$' matches only the
+                  top level 'prod' and not 'production'.
+
+        path-re:  A Go regular expression which must match part of
+                  the whole slash-separated path.
+
+        glob:     A shell-style glob which must match the whole path
+                  or one of its ancestors.  '*' matches any text
+                  within a level, '?' matches one character and
+                  '[...]' matches one character from a set ('[!...]'
+                  negates the set).  '**' matches any number of
+                  levels.  For example, 'glob:**/aws/*-key' matches
+                  keys beneath 'aws' at any depth.
+
+    Every syntax ignores case unless the pattern contains an
+    uppercase letter.  In regular expressions, escapes like '\S'
+    and classes like '\p{Lu}' don't count.
+
 
 ENVIRONMENT VARIABLES
     This section describes environment variables which can be used to
@@ -118,7 +557,51 @@ ENVIRONMENT VARIABLES
         If HUSH_ASKPASS is missing, hush prompts on the user's
         terminal.
 
+    HUSH_AUTHOR
+        The name recorded as the author of changes to leaves.  If
+        it's empty, hush uses the value of USER.
+
+    HUSH_CLIPBOARD
+        Chooses how the copy command sets the clipboard: 'osc52',
+        'xclip' or 'wl-copy'.  If it's empty, hush picks one based on
+        your environment.
+
+    HUSH_CLIPBOARD_TIMEOUT
+        How long copied values stay on the clipboard, like '30s' or
+        '2m'.  A plain number is seconds.  The default is 45s.  The
+        --timeout option takes precedence.
+
+    HUSH_DOCKER_PREFIX
+        The subtree where docker-credential-hush stores registry
+        credentials.  The default is 'docker'.
+
     HUSH_FILE
         Set this variable to the absolute path of your hush file.
         The default, if empty, is $HOME/.hush
+
+    HUSH_GIT_CREDENTIAL_PATH
+        The path layout used by the git-credential command.  The
+        placeholders {protocol}, {host}, {path} and {username} are
+        replaced by the attributes git provides.  The default is
+        'git/{host}/{username}/password'.
+
+    HUSH_IDENTITY
+        Set this variable to the filename of an age identity or an
+        OpenSSH ed25519 private key.  hush unlocks the file with that
+        key instead of asking for a password.  The key must belong to
+        one of the file's recipients.
+
+    HUSH_KEYFILE
+        Set this variable to the filename of the key file that's
+        needed to unlock your hush file, if any.  The --keyfile option
+        takes precedence.
+
+    HUSH_SIGNERS
+        Set this variable to the filename of your list of trusted
+        signers.  It defaults to ~/.hush-signers.
+
+    HUSH_SIGNING_KEY
+        Set this variable to the filename of an OpenSSH ed25519
+        private key.  hush uses it to sign the file when saving.  See
+        the signer command.
 `
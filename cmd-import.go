@@ -39,8 +39,17 @@ func CmdImport(r io.Reader, tree *Tree) ([]string, error) {
 			warnf("skipping configuration path %s", p)
 			continue
 		}
+		if p.IsMetadata() {
+			warnf("skipping metadata path %s", p)
+			continue
+		}
+		if p.IsAttachment() {
+			warnf("skipping attachment path %s", p)
+			continue
+		}
 		val := NewPlaintext([]byte(parts[1]), Private)
-		tree.set(p, val)
+		tree.setLeaf(p, val)
+		tree.Touch(p)
 	}
 	err := tree.Save()
 	return warnings, errors.Wrap(err, "import")
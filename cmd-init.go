@@ -1,7 +1,6 @@
 package hush
 
 import (
-	"bytes"
 	"crypto/rand"
 	"errors"
 	"fmt"
@@ -11,10 +10,13 @@ import (
 
 // CmdInit initializes the user's hush file, if it does not exist.
 // Informative user messages are written to w. User input, if needed,
-// is taken from input.
+// is taken from input.  If keyfile is not empty, the named key file
+// is needed to unlock the hush file.  It's created if it doesn't
+// exist.  If usePassword is false, the key file alone unlocks the hush
+// file.
 //
 // This function implements "hush init"
-func CmdInit(w io.Writer, input *os.File) error {
+func CmdInit(w io.Writer, input *os.File, keyfile string, usePassword bool) error {
 	// make sure hush file doesn't exist yet
 	hushFilename, err := HushPath()
 	if !os.IsNotExist(err) {
@@ -23,21 +25,28 @@ func CmdInit(w io.Writer, input *os.File) error {
 			hushFilename,
 		)
 	}
-
-	// prompt for passwords
-	io.WriteString(w, "Preparing to initialize your hush file. Please provide\n")
-	io.WriteString(w, "and verify a password to use for encryption.\n")
-	io.WriteString(w, "\n")
-	password, err := AskPassword(w, "Password")
-	if err != nil {
-		return err
+	if keyfile == "" && !usePassword {
+		return errors.New("Need a password, a key file or both")
 	}
-	verify, err := AskPassword(w, "Verify password")
-	if err != nil {
-		return err
+
+	var keyfileContent []byte
+	if keyfile != "" {
+		keyfileContent, err = readKeyfile(keyfile, true)
+		if err != nil {
+			return err
+		}
 	}
-	if !bytes.Equal(password, verify) {
-		return errors.New("Passwords don't match")
+
+	// prompt for passwords
+	var password []byte
+	if usePassword {
+		io.WriteString(w, "Preparing to initialize your hush file. Please provide\n")
+		io.WriteString(w, "and verify a password to use for encryption.\n")
+		io.WriteString(w, "\n")
+		password, err = askNewPassword(w)
+		if err != nil {
+			return err
+		}
 	}
 
 	// generate keys
@@ -51,27 +60,14 @@ func CmdInit(w io.Writer, input *os.File) error {
 	if err != nil {
 		return err
 	}
-	salt := make([]byte, 16) // double the RFC8018 minimum
-	_, err = rand.Read(salt)
-	if err != nil {
-		return err
-	}
-	pwKey := stretchPassword(password, salt)
 
 	t := newT(nil)
 	t.encryptionKey = encryptionKey
 	t.macKey = macKey
-	p := NewPath("hush-configuration/salt")
-	v := NewPlaintext(salt, Public)
-	t.set(p, v)
-	p = NewPath("hush-configuration/encryption-key")
-	v = NewPlaintext(encryptionKey, Private)
-	v = v.Ciphertext(pwKey)
-	t.set(p, v)
-	p = NewPath("hush-configuration/mac-key")
-	v = NewPlaintext(macKey, Private)
-	v = v.Ciphertext(pwKey)
-	t.set(p, v)
+	err = t.SetMasterPassword(password, keyfileContent)
+	if err != nil {
+		return err
+	}
 	err = t.Save()
 	if err != nil {
 		return err
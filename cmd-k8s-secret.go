@@ -0,0 +1,153 @@
+package hush
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// k8sKey matches keys Kubernetes allows in a Secret's data
+var k8sKey = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// k8sSecret is the part of a Kubernetes Secret manifest which hush
+// reads.
+type k8sSecret struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Data       map[string]string `yaml:"data"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+// CmdK8sSecret writes to w a Kubernetes Secret manifest holding the
+// leaves which match pattern.  Each key is the last component of a
+// leaf's path.  Values are base64 encoded in the data field, unless
+// stringData is true.
+//
+// This function implements "hush k8s-secret"
+func CmdK8sSecret(w io.Writer, tree *Tree, pattern, name, namespace string, stringData bool) error {
+	matched, err := tree.Filter(pattern)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]string)
+	var keys []string
+	for _, branch := range matched.branches {
+		p := branch.path
+		if !p.IsUserData() {
+			continue
+		}
+		crumbs := p.AsCrumbs()
+		key := crumbs[len(crumbs)-1]
+		if !k8sKey.MatchString(key) {
+			return fmt.Errorf("%s: %q isn't a valid key for a Secret", p, key)
+		}
+		if _, ok := values[key]; ok {
+			return fmt.Errorf("%s: more than one leaf has key %q", p, key)
+		}
+		v, err := tree.plaintext(p)
+		if err != nil {
+			return err
+		}
+		if !stringData {
+			v = base64.StdEncoding.EncodeToString([]byte(v))
+		}
+		values[key] = v
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no leaves match %q", pattern)
+	}
+	sort.Strings(keys)
+
+	var data yaml.MapSlice
+	for _, key := range keys {
+		data = append(data, yaml.MapItem{Key: key, Value: values[key]})
+	}
+	metadata := yaml.MapSlice{{Key: "name", Value: name}}
+	if namespace != "" {
+		metadata = append(metadata, yaml.MapItem{Key: "namespace", Value: namespace})
+	}
+	field := "data"
+	if stringData {
+		field = "stringData"
+	}
+	manifest := yaml.MapSlice{
+		{Key: "apiVersion", Value: "v1"},
+		{Key: "kind", Value: "Secret"},
+		{Key: "metadata", Value: metadata},
+		{Key: "type", Value: "Opaque"},
+		{Key: field, Value: data},
+	}
+	out, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// CmdImportK8sSecret reads a Kubernetes Secret manifest from r and
+// adds each of its keys to tree beneath prefix.  If prefix is empty,
+// the Secret's name is used.  As in Kubernetes, stringData takes
+// precedence over data.
+//
+// This function implements "hush import --format=k8s-secret".
+func CmdImportK8sSecret(r io.Reader, tree *Tree, prefix string) ([]string, error) {
+	input, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var secret k8sSecret
+	err = yaml.Unmarshal(input, &secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "import")
+	}
+	if secret.Kind != "Secret" {
+		return nil, fmt.Errorf("import: expected kind Secret, got %q", secret.Kind)
+	}
+	if prefix == "" {
+		prefix = secret.Metadata.Name
+	}
+	if prefix == "" {
+		return nil, errors.New("import: Secret has no name. use --path")
+	}
+
+	values := make(map[string][]byte)
+	for key, encoded := range secret.Data {
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("import: data %s: %s", key, err)
+		}
+		values[key] = value
+	}
+	for key, value := range secret.StringData {
+		values[key] = []byte(value)
+	}
+
+	var warnings []string
+	for key, value := range values {
+		if !k8sKey.MatchString(key) {
+			warnings = append(warnings, fmt.Sprintf("skipping invalid key %q", key))
+			continue
+		}
+		p := NewPath(prefix + "/" + key)
+		if err := checkSettable(p); err != nil {
+			warnings = append(warnings, fmt.Sprintf("skipping %s: %s", p, err))
+			continue
+		}
+		tree.setLeaf(p, NewPlaintext(value, Private))
+		tree.Touch(p)
+	}
+	err = tree.Save()
+	return warnings, errors.Wrap(err, "import")
+}
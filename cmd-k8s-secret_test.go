@@ -0,0 +1,70 @@
+package hush
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestK8sSecretRoundTrip(t *testing.T) {
+	useTempHushFile(t)
+	tree := newTestTree()
+	tree.set(NewPath("db/prod/username"), NewPlaintext([]byte("admin"), Private))
+	tree.set(NewPath("db/prod/password"), NewPlaintext([]byte("hunter2\n"), Private))
+
+	for _, stringData := range []bool{false, true} {
+		var manifest bytes.Buffer
+		err := CmdK8sSecret(&manifest, tree, "db/prod", "db", "prod", stringData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stringData != strings.Contains(manifest.String(), "stringData:") {
+			t.Errorf("stringData %v:\n%s", stringData, manifest.String())
+		}
+		if strings.Contains(manifest.String(), "admin") != stringData {
+			t.Errorf("values should be encoded only in data:\n%s", manifest.String())
+		}
+
+		imported := newTestTree().Empty()
+		warnings, err := CmdImportK8sSecret(&manifest, imported, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(warnings) > 0 {
+			t.Errorf("warnings: %q", warnings)
+		}
+		for p, want := range map[string]string{"db/username": "admin", "db/password": "hunter2\n"} {
+			got, err := imported.plaintext(NewPath(p))
+			if err != nil || got != want {
+				t.Errorf("stringData %v: %s = %q, %v", stringData, p, got, err)
+			}
+		}
+	}
+}
+
+func TestK8sSecretInvalid(t *testing.T) {
+	tree := newTestTree()
+	var out bytes.Buffer
+
+	// both leaves are named password
+	if err := CmdK8sSecret(&out, tree, "password", "s", "", false); err == nil {
+		t.Errorf("duplicate keys should fail")
+	}
+	tree.set(NewPath("app/api key"), NewPlaintext([]byte("x"), Private))
+	if err := CmdK8sSecret(&out, tree, "app", "s", "", false); err == nil {
+		t.Errorf("invalid key should fail")
+	}
+	if err := CmdK8sSecret(&out, tree, "nothing", "s", "", false); err == nil {
+		t.Errorf("no matching leaves should fail")
+	}
+
+	useTempHushFile(t)
+	manifest := "kind: ConfigMap\nmetadata:\n  name: s\n"
+	if _, err := CmdImportK8sSecret(strings.NewReader(manifest), tree, ""); err == nil {
+		t.Errorf("only Secrets can be imported")
+	}
+	manifest = "kind: Secret\nmetadata:\n  name: s\ndata:\n  bad: '!!!'\n"
+	if _, err := CmdImportK8sSecret(strings.NewReader(manifest), tree, ""); err == nil {
+		t.Errorf("invalid base64 should fail")
+	}
+}
@@ -0,0 +1,63 @@
+package hush
+
+import (
+	"errors"
+	"io"
+)
+
+// CmdKeyfile changes whether tree's master password needs a key file.
+// args holds the subcommand and its arguments.  The user is prompted
+// for a new master password on w, unless the key file replaces it.
+//
+// This function implements "hush keyfile"
+func CmdKeyfile(w io.Writer, tree *Tree, args []string) error {
+	usage := errors.New("Usage: hush keyfile add file [--no-password] | rm")
+	if len(args) < 1 {
+		return usage
+	}
+
+	if err := tree.requireMasterPassword("changing the key file"); err != nil {
+		return err
+	}
+
+	var password, keyfile []byte
+	var err error
+	switch args[0] {
+	case "add":
+		if len(args) < 2 || len(args) > 3 {
+			return usage
+		}
+		usePassword := true
+		if len(args) == 3 {
+			if args[2] != "--no-password" {
+				return usage
+			}
+			usePassword = false
+		}
+		keyfile, err = readKeyfile(args[1], true)
+		if err != nil {
+			return err
+		}
+		if usePassword {
+			io.WriteString(w, "Choose a master password to use with the key file.\n")
+			password, err = askNewPassword(w)
+		}
+	case "rm":
+		if len(args) != 1 {
+			return usage
+		}
+		io.WriteString(w, "Choose a master password to use without a key file.\n")
+		password, err = askNewPassword(w)
+	default:
+		return usage
+	}
+	if err != nil {
+		return err
+	}
+
+	err = tree.SetMasterPassword(password, keyfile)
+	if err != nil {
+		return err
+	}
+	return tree.Save()
+}
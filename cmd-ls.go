@@ -2,11 +2,17 @@ package hush
 
 import "io"
 
-// CmdLs prints to w that portion of tree which matches pattern.
+// CmdLs prints to w that portion of tree which matches pattern.  If
+// long is true, each leaf's metadata is printed too.
 //
 // This function implements "hush ls"
-func CmdLs(w io.Writer, tree *Tree, pattern string) error {
-	tree = tree.Filter(pattern)
-	err := tree.Print(w)
-	return err
+func CmdLs(w io.Writer, tree *Tree, pattern string, long bool) error {
+	tree, err := tree.Filter(pattern)
+	if err != nil {
+		return err
+	}
+	if long {
+		return tree.PrintLong(w)
+	}
+	return tree.Print(w)
 }
@@ -0,0 +1,102 @@
+package hush
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"text/template"
+)
+
+// CmdMaterialize creates a named pipe at fifo and, each time a reader
+// opens it, writes the template in filename rendered against tree.  The
+// rendered text never touches the disk.  It runs until interrupted,
+// then removes the pipe.  Progress and errors go to w.
+//
+// This function implements "hush materialize"
+func CmdMaterialize(w io.Writer, tree *Tree, filename, fifo string) error {
+	// a reader can't tell an error from an empty file, so find missing
+	// paths before anyone can open the pipe
+	check, err := parseTemplate(tree, filename, true)
+	if err != nil {
+		return err
+	}
+	if err := check.Execute(ioutil.Discard, nil); err != nil {
+		return err
+	}
+	tmpl, err := parseTemplate(tree, filename, false)
+	if err != nil {
+		return err
+	}
+	if err := mkfifo(fifo); err != nil {
+		return err
+	}
+
+	// remove the pipe when we're stopped
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		<-signals
+		os.Remove(fifo)
+		os.Exit(0)
+	}()
+	defer os.Remove(fifo)
+
+	fmt.Fprintf(w, "Serving %s at %s. Interrupt to stop.\n", filename, fifo)
+	for {
+		if err := materializeOnce(w, tmpl, fifo); err != nil {
+			return err
+		}
+	}
+}
+
+// materializeOnce waits for a reader to open fifo and writes tmpl's
+// rendered text to it.  If rendering fails, the error is reported to w
+// and returned, which removes the pipe so that later readers fail
+// instead of seeing an empty file.
+//
+// Before the reader sees end of file, fifo is replaced by a new pipe.
+// Opening the old one for writing again would succeed at once while
+// this reader still has it open, sending it a second copy.  Readers
+// which open fifo later wait for the next call.
+func materializeOnce(w io.Writer, tmpl *template.Template, fifo string) error {
+	// blocks until someone opens the pipe for reading
+	pipe, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer pipe.Close()
+
+	// render completely first so errors don't yield partial files
+	var buf bytes.Buffer
+	defer func() { wipe(buf.Bytes()) }()
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		fmt.Fprintf(w, "error: %s\n", err)
+		os.Remove(fifo)
+		return err
+	}
+	if _, err := pipe.Write(buf.Bytes()); err != nil {
+		fmt.Fprintf(w, "error: %s\n", err) // the reader went away
+	}
+
+	next := filepath.Join(filepath.Dir(fifo), fmt.Sprintf(".hush-fifo-%d", os.Getpid()))
+	if err := mkfifo(next); err != nil {
+		return err
+	}
+	if err := os.Rename(next, fifo); err != nil {
+		os.Remove(next)
+		return err
+	}
+	return nil
+}
+
+// wipe overwrites data with zeros.
+func wipe(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
@@ -0,0 +1,91 @@
+//go:build unix
+
+package hush
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestMaterializeOnce(t *testing.T) {
+	tree := newTestTree()
+	fifo := filepath.Join(t.TempDir(), "netrc")
+	if err := mkfifo(fifo); err != nil {
+		t.Fatal(err)
+	}
+	text := `password {{secret "paypal.com/personal/password"}}`
+	tmpl := template.Must(template.New("t").Funcs(templateFuncs(tree, false)).Parse(text))
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 2; i++ {
+			if err := materializeOnce(ioutil.Discard, tmpl, fifo); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	// each reader gets exactly one copy
+	for i := 0; i < 2; i++ {
+		got, err := ioutil.ReadFile(fifo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "password secret" {
+			t.Errorf("read %d: got %q", i+1, got)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(fifo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode()&os.ModeNamedPipe == 0 || stat.Mode().Perm() != 0600 {
+		t.Errorf("replacement pipe has mode %s", stat.Mode())
+	}
+}
+
+func TestMaterializeRenderError(t *testing.T) {
+	tree := newTestTree()
+	dir := t.TempDir()
+	fifo := filepath.Join(dir, "netrc")
+
+	// missing paths are found before the pipe exists
+	filename := filepath.Join(dir, "netrc.tmpl")
+	err := ioutil.WriteFile(filename, []byte(`{{secret "example.com/password"}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CmdMaterialize(ioutil.Discard, tree, filename, fifo); err == nil {
+		t.Fatal("missing path should be an error")
+	}
+	if _, err := os.Stat(fifo); !os.IsNotExist(err) {
+		t.Errorf("pipe shouldn't have been created")
+	}
+
+	// later failures are reported and remove the pipe
+	if err := mkfifo(fifo); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := template.Must(template.New("t").Funcs(templateFuncs(tree, false)).Parse(`{{secret "example.com/password"}}`))
+	go ioutil.ReadFile(fifo)
+	var out bytes.Buffer
+	if err := materializeOnce(&out, tmpl, fifo); err == nil {
+		t.Errorf("render error should be returned")
+	}
+	if !strings.Contains(out.String(), "error: ") {
+		t.Errorf("render error wasn't reported: %q", out.String())
+	}
+	if _, err := os.Stat(fifo); !os.IsNotExist(err) {
+		t.Errorf("pipe should have been removed")
+	}
+}
@@ -0,0 +1,65 @@
+package hush
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CmdMeta displays or changes the metadata of the leaf at p.  With no
+// args, all metadata is written to w.  Otherwise, args holds a field
+// name and its new value.
+//
+// This function implements "hush meta"
+func CmdMeta(w io.Writer, tree *Tree, p Path, args []string) error {
+	if len(args) == 0 {
+		if _, ok := tree.get(p); !ok {
+			return fmt.Errorf("no such leaf: %s", p)
+		}
+		meta, err := tree.Metadata(p)
+		if err != nil {
+			return err
+		}
+		for _, field := range metadataFields {
+			if v, ok := meta[field]; ok {
+				fmt.Fprintf(w, "%s: %s\n", field, v)
+			}
+		}
+		return nil
+	}
+	if len(args) < 2 {
+		return errors.New("Usage: hush meta path [field value]")
+	}
+
+	field, value := args[0], strings.Join(args[1:], " ")
+	switch field {
+	case "created", "modified", "modified-by", "filename", "mime-type", "size":
+		return fmt.Errorf("%s is maintained by hush", field)
+	}
+	err := tree.SetMetadata(p, field, value)
+	if err != nil {
+		return err
+	}
+	return tree.Save()
+}
+
+// CmdTimestamps changes whether metadata timestamps are stored
+// encrypted ("private") or not ("public").
+//
+// This function implements "hush timestamps"
+func CmdTimestamps(tree *Tree, privacy string) error {
+	var err error
+	switch privacy {
+	case "public":
+		err = tree.SetPublicTimestamps(true)
+	case "private":
+		err = tree.SetPublicTimestamps(false)
+	default:
+		return errors.New("Usage: hush timestamps public|private")
+	}
+	if err != nil {
+		return err
+	}
+	return tree.Save()
+}
@@ -0,0 +1,124 @@
+package hush
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+// CmdPick lets the user choose a leaf by fuzzy matching its path
+// against query, then writes the leaf's value to w.  If toClipboard is true,
+// the value goes on the clipboard for timeout instead.  If stdout isn't
+// a terminal, it writes the matching paths, best match first.
+//
+// This function implements "hush pick"
+func CmdPick(w io.Writer, tree *Tree, query string, toClipboard bool, timeout time.Duration) error {
+	tree.Sort()
+	var paths []Path
+	for _, branch := range tree.branches {
+		if branch.path.IsUserData() {
+			paths = append(paths, branch.path)
+		}
+	}
+
+	if !isTerminal(os.Stdout) {
+		for _, p := range fuzzyRank(paths, query) {
+			fmt.Fprintln(w, p)
+		}
+		return nil
+	}
+
+	p, err := pick(paths, query)
+	if err != nil {
+		return err
+	}
+	if toClipboard {
+		return CmdCopy(os.Stderr, tree, p, timeout)
+	}
+	value, err := tree.plaintext(p)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, value)
+	return nil
+}
+
+// pick shows a live-filtered list of paths on the terminal and returns
+// the one the user chooses.
+func pick(paths []Path, query string) (Path, error) {
+	t, err := openTTY()
+	if err != nil {
+		return "", err
+	}
+	defer t.Close()
+	defer t.Printf("\r\x1b[J")
+
+	selected := 0
+	for {
+		ranked := fuzzyRank(paths, query)
+		width, height := t.Size()
+		n := len(ranked)
+		if n > height-1 {
+			n = height - 1
+		}
+		if n > 15 {
+			n = 15
+		}
+		if selected >= n {
+			selected = n - 1
+		}
+		if selected < 0 {
+			selected = 0
+		}
+
+		// draw the prompt and matches, then return to the prompt
+		t.Printf("\r\x1b[J> %s", query)
+		for i, p := range ranked[:n] {
+			line := p.String()
+			if max := width - 3; max >= 0 && utf8.RuneCountInString(line) > max {
+				line = string([]rune(line)[:max])
+			}
+			if i == selected {
+				t.Printf("\r\n\x1b[7m> %s\x1b[0m", line)
+			} else {
+				t.Printf("\r\n  %s", line)
+			}
+		}
+		if n > 0 {
+			t.Printf("\x1b[%dA", n)
+		}
+		t.Printf("\r\x1b[%dC", 2+utf8.RuneCountInString(query))
+
+		k, r, err := t.ReadKey()
+		if err != nil {
+			return "", err
+		}
+		switch k {
+		case keyRune:
+			query += string(r)
+			selected = 0
+		case keyBackspace:
+			if query != "" {
+				_, size := utf8.DecodeLastRuneInString(query)
+				query = query[:len(query)-size]
+				selected = 0
+			}
+		case keyCtrlU:
+			query = ""
+			selected = 0
+		case keyUp:
+			selected--
+		case keyDown, keyTab:
+			selected++
+		case keyEnter:
+			if n > 0 {
+				return ranked[selected], nil
+			}
+		case keyEscape, keyCtrlC, keyCtrlD:
+			return "", errors.New("nothing picked")
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package hush
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CmdRecipient manages the recipients who may unlock tree.  args
+// holds the subcommand and its arguments.
+//
+// This function implements "hush recipient"
+func CmdRecipient(w io.Writer, tree *Tree, args []string) error {
+	usage := errors.New("Usage: hush recipient add|rm|ls [name [public-key]]")
+	if len(args) < 1 {
+		return usage
+	}
+
+	switch args[0] {
+	case "ls":
+		for _, name := range tree.Recipients() {
+			id := "password"
+			if !tree.IsPasswordRecipient(name) {
+				id, _ = tree.RecipientKey(name)
+			}
+			fmt.Fprintf(w, "%s\t%s\n", name, id)
+		}
+		return nil
+	case "add":
+		if len(args) < 2 {
+			return usage
+		}
+		name := args[1]
+		var err error
+		if len(args) == 2 {
+			io.WriteString(os.Stderr, "Choose a password for recipient "+name+"\n")
+			var password []byte
+			password, err = askNewPassword(os.Stderr)
+			if err != nil {
+				return err
+			}
+			err = tree.AddPasswordRecipient(name, password)
+		} else {
+			id := strings.Join(args[2:], " ")
+			err = tree.AddRecipient(name, id)
+		}
+		if err != nil {
+			return err
+		}
+		return tree.Save()
+	case "rm":
+		if len(args) != 2 {
+			return usage
+		}
+		err := tree.RemoveRecipient(args[1])
+		if err != nil {
+			return err
+		}
+		return tree.Save()
+	}
+	return usage
+}
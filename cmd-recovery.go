@@ -0,0 +1,120 @@
+package hush
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CmdRecoverySplit writes n recovery shares of tree's keys to w.  Any
+// k of the shares can restore access to the tree if the master
+// password is lost.
+//
+// This function implements "hush recovery split"
+func CmdRecoverySplit(w io.Writer, tree *Tree, n, k int) error {
+	secret := append(append([]byte{}, tree.encryptionKey...), tree.macKey...)
+	shares, err := shamirSplit(secret, n, k)
+	if err != nil {
+		return err
+	}
+
+	for i, share := range shares {
+		fmt.Fprintf(w, "Share %d of %d (any %d restore your keys):\n", i+1, n, k)
+		words := newRecoveryShare(k, share).words()
+		for len(words) > 0 {
+			line := words
+			if len(line) > 10 {
+				line = line[:10]
+			}
+			words = words[len(line):]
+			io.WriteString(w, "    "+strings.Join(line, " ")+"\n")
+		}
+		io.WriteString(w, "\n")
+	}
+	return nil
+}
+
+// CmdRecoveryRestore reads recovery shares from r, reconstructs tree's
+// keys and then asks for a new master password.  Prompts are written
+// to w.
+//
+// This function implements "hush recovery restore"
+func CmdRecoveryRestore(w io.Writer, r io.Reader, tree *Tree) error {
+	scanner := bufio.NewScanner(r)
+
+	var shares [][]byte
+	needed := 2
+	for len(shares) < needed {
+		fmt.Fprintf(w, "Share %d: ", len(shares)+1)
+		words, err := readRecoveryShare(scanner)
+		if err != nil {
+			return err
+		}
+		if len(words) == 0 {
+			return errors.New("not enough shares")
+		}
+
+		share, err := parseRecoveryShare(words)
+		if err != nil {
+			fmt.Fprintf(w, "%s\nPlease try again.\n", err)
+			continue
+		}
+		duplicate := false
+		for _, s := range shares {
+			duplicate = duplicate || s[0] == share.share()[0]
+		}
+		if duplicate {
+			io.WriteString(w, "That share was already entered.\n")
+			continue
+		}
+		needed = share.needed()
+		shares = append(shares, share.share())
+	}
+
+	secret, err := shamirCombine(shares)
+	if err != nil {
+		return err
+	}
+	tree.encryptionKey = secret[:32]
+	tree.macKey = secret[32:]
+	err = tree.verifyChecksum()
+	if err != nil {
+		return errors.New("recovered keys don't match this hush file")
+	}
+
+	io.WriteString(w, "Keys recovered. Choose a new master password.\n")
+	password, err := askNewPassword(w)
+	if err != nil {
+		return err
+	}
+	err = tree.SetMasterPassword(password, nil)
+	if err != nil {
+		return err
+	}
+	return tree.Save()
+}
+
+// readRecoveryShare reads the words of one share from scanner.  A share
+// ends with a blank line or with the line that completes its words, so
+// a share with missing or extra words can't swallow part of the next
+// one.  Header lines like those "hush recovery split" prints are
+// skipped.
+func readRecoveryShare(scanner *bufio.Scanner) ([]string, error) {
+	var words []string
+	for len(words) < recoveryShareSize && scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "Share ") {
+			continue
+		}
+		if line == "" {
+			if len(words) > 0 {
+				break
+			}
+			continue
+		}
+		words = append(words, strings.Fields(line)...)
+	}
+	return words, scanner.Err()
+}
@@ -0,0 +1,41 @@
+package hush
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadRecoveryShare(t *testing.T) {
+	shares, err := shamirSplit(make([]byte, 64), 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := newRecoveryShare(2, shares[0]).words()
+	short := words[:len(words)-1]
+	lines := func(words []string) string {
+		var b strings.Builder
+		for len(words) > 0 {
+			n := 10
+			if len(words) < n {
+				n = len(words)
+			}
+			b.WriteString("    " + strings.Join(words[:n], " ") + "\n")
+			words = words[n:]
+		}
+		return b.String()
+	}
+	input := "Share 1 of 2 (any 2 restore your keys):\n" + lines(short) + "\n" +
+		"Share 2 of 2 (any 2 restore your keys):\n" + lines(words) + lines(words)
+
+	scanner := bufio.NewScanner(strings.NewReader(input))
+	for i, want := range []int{recoveryShareSize - 1, recoveryShareSize, recoveryShareSize, 0} {
+		got, err := readRecoveryShare(scanner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != want {
+			t.Errorf("share %d: got %d words, want %d", i+1, len(got), want)
+		}
+	}
+}
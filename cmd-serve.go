@@ -0,0 +1,352 @@
+package hush
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// vaultServer answers a subset of HashiCorp Vault's HTTP API, as if
+// the tree were mounted as a KV version 2 secrets engine at "secret/".
+// A Vault secret is the set of leaves immediately beneath a path.  A
+// leaf on its own is a secret with a single key named "value".
+type vaultServer struct {
+	mu       sync.Mutex // guards tree
+	tree     *Tree
+	token    string
+	readOnly bool
+	log      io.Writer
+}
+
+const (
+	vaultDataPrefix  = "/v1/secret/data/"
+	vaultMountPrefix = "/v1/sys/internal/ui/mounts/"
+)
+
+// CmdServe serves tree over HTTP on listen, which is either a TCP
+// address like "127.0.0.1:8200" or a Unix socket like
+// "unix:/path/sock".  Clients authenticate with a random token which
+// is printed to w at startup.  If readOnly is true, clients can't
+// change the tree.  Each request is logged to w, without values.
+//
+// This function implements "hush serve"
+func CmdServe(w io.Writer, tree *Tree, listen string, readOnly bool) error {
+	token, err := randomBytes(16)
+	if err != nil {
+		return err
+	}
+	s := &vaultServer{
+		tree:     tree,
+		token:    "hush." + hex.EncodeToString(token),
+		readOnly: readOnly,
+		log:      w,
+	}
+
+	var l net.Listener
+	var addr string
+	if strings.HasPrefix(listen, "unix:") {
+		socket := strings.TrimPrefix(listen, "unix:")
+		l, err = net.Listen("unix", socket)
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(socket, safePerm); err != nil {
+			l.Close()
+			return err
+		}
+
+		// remove the socket when we're stopped
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		go func() {
+			<-signals
+			os.Remove(socket)
+			os.Exit(0)
+		}()
+		addr = "unix://" + socket
+	} else {
+		l, err = net.Listen("tcp", listen)
+		if err != nil {
+			return err
+		}
+		addr = "http://" + l.Addr().String()
+	}
+	defer l.Close()
+
+	fmt.Fprintf(w, "VAULT_ADDR=%s\n", addr)
+	fmt.Fprintf(w, "VAULT_TOKEN=%s\n", s.token)
+	if readOnly {
+		fmt.Fprintf(w, "Serving read only. Interrupt to stop.\n")
+	} else {
+		fmt.Fprintf(w, "Serving. Interrupt to stop.\n")
+	}
+	return http.Serve(l, s)
+}
+
+// statusWriter remembers the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (s *vaultServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	s.serve(sw, r)
+
+	// never log values.  paths are plaintext in the hush file anyway
+	s.mu.Lock()
+	fmt.Fprintf(s.log, "%s %s %s %d\n", time.Now().UTC().Format(time.RFC3339), r.Method, r.URL.Path, sw.status)
+	s.mu.Unlock()
+}
+
+func (s *vaultServer) serve(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("X-Vault-Token")
+	if token == "" {
+		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		vaultError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	// the Vault CLI asks which version of the KV engine is mounted
+	if strings.HasPrefix(r.URL.Path, vaultMountPrefix) {
+		vaultReply(w, http.StatusOK, map[string]interface{}{
+			"path":    "secret/",
+			"type":    "kv",
+			"options": map[string]string{"version": "2"},
+		})
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, vaultDataPrefix) {
+		vaultError(w, http.StatusNotFound)
+		return
+	}
+	p := NewPath(strings.Trim(strings.TrimPrefix(r.URL.Path, vaultDataPrefix), "/"))
+	if p == "" || !p.IsUserData() {
+		vaultError(w, http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case "GET":
+		s.read(w, p)
+	case "POST", "PUT":
+		if s.readOnly {
+			vaultError(w, http.StatusForbidden, "server is read only")
+			return
+		}
+		s.write(w, r.Body, p)
+	case "DELETE":
+		if s.readOnly {
+			vaultError(w, http.StatusForbidden, "server is read only")
+			return
+		}
+		s.remove(w, p)
+	default:
+		vaultError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// secretPaths returns the leaves which make up the secret at p, keyed
+// by their Vault key.
+func (s *vaultServer) secretPaths(p Path) map[string]Path {
+	paths := make(map[string]Path)
+	if _, ok := s.tree.get(p); ok {
+		paths["value"] = p
+		return paths
+	}
+	for _, branch := range s.tree.branches {
+		leaf := branch.path
+		if leaf.IsUserData() && p.HasDescendant(leaf) && leaf.Parent() == p {
+			paths[strings.TrimPrefix(string(leaf), string(p)+"/")] = leaf
+		}
+	}
+	return paths
+}
+
+// secretMetadata describes the secret made up of paths.  hush keeps
+// no history, so every secret is at version 1.
+func (s *vaultServer) secretMetadata(paths map[string]Path) map[string]interface{} {
+	var modified time.Time
+	for _, leaf := range paths {
+		if when, ok := s.tree.Timestamp(leaf, "modified"); ok && when.After(modified) {
+			modified = when
+		}
+	}
+	return map[string]interface{}{
+		"created_time":    modified.UTC().Format(time.RFC3339Nano),
+		"custom_metadata": nil,
+		"deletion_time":   "",
+		"destroyed":       false,
+		"version":         1,
+	}
+}
+
+func (s *vaultServer) read(w http.ResponseWriter, p Path) {
+	paths := s.secretPaths(p)
+	if len(paths) == 0 {
+		vaultError(w, http.StatusNotFound)
+		return
+	}
+	data := make(map[string]string)
+	for key, leaf := range paths {
+		if s.tree.IsAttachment(leaf) {
+			vaultError(w, http.StatusBadRequest, attachmentError(leaf).Error())
+			return
+		}
+		value, err := s.tree.plaintext(leaf)
+		if err != nil {
+			vaultError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		data[key] = value
+	}
+	vaultReply(w, http.StatusOK, map[string]interface{}{
+		"data":     data,
+		"metadata": s.secretMetadata(paths),
+	})
+}
+
+// write replaces the secret at p.  Like Vault, keys missing from the
+// request are removed.
+func (s *vaultServer) write(w http.ResponseWriter, body io.Reader, p Path) {
+	var request struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := json.NewDecoder(body).Decode(&request); err != nil {
+		vaultError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(request.Data) == 0 {
+		vaultError(w, http.StatusBadRequest, "no data provided")
+		return
+	}
+
+	paths := make(map[string]Path)
+	if _, ok := s.tree.get(p); ok {
+		if _, ok := request.Data["value"]; !ok || len(request.Data) > 1 {
+			vaultError(w, http.StatusBadRequest, p.String()+" is a leaf. its only key is 'value'")
+			return
+		}
+		paths["value"] = p
+	} else {
+		for key := range request.Data {
+			if key == "" || strings.Contains(key, "/") {
+				vaultError(w, http.StatusBadRequest, fmt.Sprintf("invalid key %q", key))
+				return
+			}
+			leaf := NewPath(p.String() + "/" + key)
+			if err := checkSettable(leaf); err != nil {
+				vaultError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if s.hasDescendants(leaf) {
+				vaultError(w, http.StatusBadRequest, leaf.String()+" is a subtree")
+				return
+			}
+			if above, ok := s.tree.leafAbove(leaf); ok {
+				vaultError(w, http.StatusBadRequest, above.String()+" is a leaf")
+				return
+			}
+			paths[key] = leaf
+		}
+	}
+	for _, leaf := range paths {
+		if s.tree.IsAttachment(leaf) {
+			vaultError(w, http.StatusBadRequest, attachmentError(leaf).Error())
+			return
+		}
+	}
+
+	// keep memory in step with the disk if saving fails
+	before := s.tree.Clone()
+	for key, leaf := range s.secretPaths(p) {
+		if _, ok := paths[key]; !ok {
+			s.tree.Delete(leaf)
+		}
+	}
+	for key, leaf := range paths {
+		s.tree.setLeaf(leaf, NewPlaintext([]byte(request.Data[key]), Private))
+		s.tree.Touch(leaf)
+	}
+	if err := s.tree.Save(); err != nil {
+		s.tree = before
+		vaultError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	vaultReply(w, http.StatusOK, s.secretMetadata(paths))
+}
+
+// hasDescendants returns true if any user data lies beneath p.
+func (s *vaultServer) hasDescendants(p Path) bool {
+	for _, branch := range s.tree.branches {
+		if branch.path.IsUserData() && p.HasDescendant(branch.path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *vaultServer) remove(w http.ResponseWriter, p Path) {
+	paths := s.secretPaths(p)
+	if len(paths) == 0 {
+		vaultError(w, http.StatusNotFound)
+		return
+	}
+	before := s.tree.Clone()
+	for _, leaf := range paths {
+		s.tree.Delete(leaf)
+	}
+	if err := s.tree.Save(); err != nil {
+		s.tree = before
+		vaultError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// vaultReply writes data in the envelope Vault uses for responses.
+func vaultReply(w http.ResponseWriter, status int, data interface{}) {
+	id, _ := randomBytes(16)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"request_id":     hex.EncodeToString(id),
+		"lease_id":       "",
+		"renewable":      false,
+		"lease_duration": 0,
+		"data":           data,
+		"wrap_info":      nil,
+		"warnings":       nil,
+		"auth":           nil,
+	})
+}
+
+// vaultError writes an error response like Vault's.
+func vaultError(w http.ResponseWriter, status int, errs ...string) {
+	if errs == nil {
+		errs = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]string{"errors": errs})
+}
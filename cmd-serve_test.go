@@ -0,0 +1,181 @@
+package hush
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// vaultRequest sends a request to s with its token and returns the
+// response.
+func vaultRequest(s *vaultServer, method, url, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, url, strings.NewReader(body))
+	r.Header.Set("X-Vault-Token", s.token)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestServeRead(t *testing.T) {
+	tree := newTestTree()
+	tree.set(NewPath("paypal.com/personal/username"), NewPlaintext([]byte("bob"), Private))
+	tree.set(NewPath("paypal.com/personal/old/password"), NewPlaintext([]byte("older"), Private))
+	tree.Touch(NewPath("paypal.com/personal/username"))
+	s := &vaultServer{tree: tree, token: "token", log: ioutil.Discard}
+	read := func(path string) (map[string]string, map[string]interface{}) {
+		w := vaultRequest(s, "GET", vaultDataPrefix+path, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d: %s", path, w.Code, w.Body)
+		}
+		var response struct {
+			Data struct {
+				Data     map[string]string
+				Metadata map[string]interface{}
+			}
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Data.Data, response.Data.Metadata
+	}
+
+	// a secret is the leaves immediately beneath a path
+	data, metadata := read("paypal.com/personal")
+	if len(data) != 2 || data["password"] != "secret" || data["username"] != "bob" {
+		t.Errorf("got data %q", data)
+	}
+	if metadata["version"] != 1.0 || metadata["destroyed"] != false {
+		t.Errorf("got metadata %v", metadata)
+	}
+	if created, _ := metadata["created_time"].(string); strings.HasPrefix(created, "0001") {
+		t.Errorf("created_time should come from the newest leaf: %q", created)
+	}
+
+	// a leaf is a secret with one key
+	data, _ = read("paypal.com/personal/password/")
+	if len(data) != 1 || data["value"] != "secret" {
+		t.Errorf("got data %q", data)
+	}
+
+	// the Vault CLI checks the engine's version
+	w := vaultRequest(s, "GET", vaultMountPrefix+"secret/paypal.com", "")
+	var mount struct {
+		Data struct {
+			Type    string
+			Options map[string]string
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &mount); err != nil {
+		t.Fatal(err)
+	}
+	if mount.Data.Type != "kv" || mount.Data.Options["version"] != "2" {
+		t.Errorf("got mount %+v", mount.Data)
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	s := &vaultServer{tree: newTestTree(), token: "token", readOnly: true, log: ioutil.Discard}
+	tests := []struct {
+		method, url string
+		status      int
+	}{
+		{"GET", vaultDataPrefix + "nothing/here", http.StatusNotFound},
+		{"GET", vaultDataPrefix + "hush-configuration/salt", http.StatusNotFound},
+		{"GET", "/v1/secret/metadata/paypal.com", http.StatusNotFound},
+		{"POST", vaultDataPrefix + "db", http.StatusForbidden},
+		{"DELETE", vaultDataPrefix + "paypal.com/personal", http.StatusForbidden},
+		{"PATCH", vaultDataPrefix + "paypal.com/personal", http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		w := vaultRequest(s, test.method, test.url, `{"data":{"password":"x"}}`)
+		if w.Code != test.status {
+			t.Errorf("%s %s: got status %d, want %d", test.method, test.url, w.Code, test.status)
+		}
+		if !strings.Contains(w.Body.String(), `"errors"`) {
+			t.Errorf("%s %s: want Vault's error shape, got %s", test.method, test.url, w.Body)
+		}
+	}
+
+	// requests need the token
+	for _, header := range []string{"", "X-Vault-Token", "Authorization"} {
+		r := httptest.NewRequest("GET", vaultDataPrefix+"paypal.com/personal", nil)
+		if header != "" {
+			r.Header.Set(header, "wrong")
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%q: got status %d", header, w.Code)
+		}
+	}
+	r := httptest.NewRequest("GET", vaultDataPrefix+"paypal.com/personal", nil)
+	r.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("bearer token: got status %d", w.Code)
+	}
+}
+
+func TestServeDeleteThenWrite(t *testing.T) {
+	useTempHushFile(t)
+	s := &vaultServer{tree: newTestTree(), token: "token", log: ioutil.Discard}
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		return vaultRequest(s, method, vaultDataPrefix+path, body)
+	}
+
+	if w := request("DELETE", "paypal.com/personal", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: got status %d", w.Code)
+	}
+	if w := request("POST", "db/prod", `{"data":{"password":"hunter2"}}`); w.Code != http.StatusOK {
+		t.Fatalf("write: got status %d: %s", w.Code, w.Body)
+	}
+	w := request("GET", "db/prod", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("read: got status %d", w.Code)
+	}
+	var response struct {
+		Data struct {
+			Data map[string]string
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if got := response.Data.Data["password"]; got != "hunter2" {
+		t.Errorf("read: got password %q", got)
+	}
+	if w := request("GET", "paypal.com/personal", ""); w.Code != http.StatusNotFound {
+		t.Errorf("deleted secret: got status %d", w.Code)
+	}
+
+	// the new secret was saved
+	saved, err := LoadTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := saved.get(NewPath("db/prod/password")); !ok {
+		t.Errorf("db/prod/password is missing from the hush file")
+	}
+	if _, ok := saved.get(NewPath("paypal.com/personal/password")); ok {
+		t.Errorf("paypal.com/personal/password should be gone from the hush file")
+	}
+}
+
+func TestServeWriteBeneathLeaf(t *testing.T) {
+	useTempHushFile(t)
+	s := &vaultServer{tree: newTestTree(), token: "token", log: ioutil.Discard}
+	w := vaultRequest(s, "POST", vaultDataPrefix+"paypal.com/personal/password/extra", `{"data":{"x":"1"}}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+	if _, ok := s.tree.get(NewPath("paypal.com/personal/password/extra/x")); ok {
+		t.Errorf("paypal.com/personal/password/extra/x should not exist")
+	}
+	if err := s.tree.Save(); err != nil {
+		t.Errorf("saving: %s", err)
+	}
+}
@@ -9,14 +9,35 @@ import (
 //
 // This function implements "hush set"
 func CmdSet(w io.Writer, tree *Tree, p Path, v *Value) error {
+	if err := checkSettable(p); err != nil {
+		return err
+	}
+	tree.setLeaf(p, v)
+	tree.Touch(p)
+	t, err := tree.Filter(p.Parent().String())
+	if err != nil {
+		return err
+	}
+	t.Print(w)
+	return tree.Save()
+}
+
+// checkSettable returns an error if users may not set p's value.
+func checkSettable(p Path) error {
 	if p.IsConfiguration() {
 		return errors.New("Can't set a configuration path")
 	}
 	if p.IsChecksum() {
 		return errors.New("Can't set file checksum manually")
 	}
-	tree.set(p, v)
-	t := tree.Filter(p.Parent().String())
-	t.Print(w)
-	return tree.Save()
+	if p.IsSignature() {
+		return errors.New("Can't set file signature manually")
+	}
+	if p.IsMetadata() {
+		return errors.New("Can't set metadata directly. Try 'hush meta'")
+	}
+	if p.IsAttachment() {
+		return errors.New("Can't set attachments directly. Try 'hush attach'")
+	}
+	return nil
 }
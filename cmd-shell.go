@@ -0,0 +1,301 @@
+package hush
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// shell is the state of an interactive "hush shell" session.  Edits
+// accumulate in tree until the user saves them.
+type shell struct {
+	tree    *Tree
+	term    *terminal.Terminal
+	cwd     Path // current path prefix. empty at the root
+	changes int  // unsaved edits
+}
+
+var shellCommands = []string{"cd", "get", "help", "ls", "pwd", "quit", "rm", "save", "set"}
+
+const shellHelp = `Commands:
+    cd [path]      change the current path.  '..' goes up, '/' is the root
+    get path       print the value at path
+    help           show this help
+    ls [pattern]   list leaves beneath the current path
+    pwd            print the current path
+    quit           leave the shell.  'quit!' discards unsaved changes
+    rm path        remove path and its descendants
+    save           write changes to the hush file
+    set path       set the value at path.  hush prompts for the value
+`
+
+var errShellQuit = errors.New("quit")
+
+// CmdShell runs an interactive session on the user's terminal which
+// unlocks tree once and runs many commands against it.
+//
+// This function implements "hush shell"
+func CmdShell(tree *Tree) error {
+	t, err := openTTY()
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	sh := &shell{tree: tree}
+	sh.term = terminal.NewTerminal(t.file, "hush> ")
+	sh.term.AutoCompleteCallback = sh.complete
+	if width, height := t.Size(); width > 0 {
+		sh.term.SetSize(width, height)
+	}
+	io.WriteString(sh.term, "Type 'help' for a list of commands.\n")
+	return sh.loop()
+}
+
+// loop runs commands read from the terminal until the user quits.  If
+// reading fails, nobody is left to answer questions, so unsaved changes
+// are discarded.
+func (sh *shell) loop() error {
+	for {
+		line, err := sh.term.ReadLine()
+		if err != nil {
+			if sh.changes > 0 {
+				fmt.Fprintf(sh.term, "warning: discarding %d unsaved changes\n", sh.changes)
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		err = sh.run(strings.Fields(line))
+		if err == errShellQuit {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(sh.term, "error: %s\n", err)
+		}
+	}
+}
+
+// run executes a single command.
+func (sh *shell) run(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	command, args := args[0], args[1:]
+	switch command {
+	case "cd":
+		return sh.cd(args)
+	case "get":
+		return sh.get(args)
+	case "help":
+		io.WriteString(sh.term, shellHelp)
+		return nil
+	case "ls":
+		return sh.ls(args)
+	case "pwd":
+		fmt.Fprintf(sh.term, "/%s\n", sh.cwd)
+		return nil
+	case "quit", "exit":
+		if sh.changes > 0 {
+			return fmt.Errorf("%d unsaved changes. 'save' them or 'quit!' to discard", sh.changes)
+		}
+		return errShellQuit
+	case "quit!":
+		return errShellQuit
+	case "rm":
+		return sh.rm(args)
+	case "save":
+		return sh.save()
+	case "set":
+		return sh.set(args)
+	}
+	return fmt.Errorf("unknown command %q. try 'help'", command)
+}
+
+// resolve converts a path relative to the current path into a full
+// path.  A leading slash makes a path absolute and ".." means the
+// parent.
+func (sh *shell) resolve(s string) Path {
+	var crumbs []string
+	if !strings.HasPrefix(s, "/") && sh.cwd != "" {
+		crumbs = sh.cwd.AsCrumbs()
+	}
+	for _, crumb := range strings.Split(s, "/") {
+		switch crumb {
+		case "", ".":
+		case "..":
+			if len(crumbs) > 0 {
+				crumbs = crumbs[:len(crumbs)-1]
+			}
+		default:
+			crumbs = append(crumbs, crumb)
+		}
+	}
+	return NewPath(strings.Join(crumbs, "/"))
+}
+
+// within returns true if p is at or beneath dir.  Everything is within
+// the root.
+func within(dir, p Path) bool {
+	return dir == "" || dir == p || dir.HasDescendant(p)
+}
+
+func (sh *shell) cd(args []string) error {
+	if len(args) > 1 {
+		return errors.New("Usage: cd [path]")
+	}
+	if len(args) == 0 {
+		sh.cwd = ""
+		return nil
+	}
+	dir := sh.resolve(args[0])
+	if _, ok := sh.tree.get(dir); ok && dir != "" {
+		return fmt.Errorf("%s is a leaf", dir)
+	}
+	for _, branch := range sh.tree.branches {
+		if branch.val != nil && branch.path.IsUserData() && within(dir, branch.path) {
+			sh.cwd = dir
+			return nil
+		}
+	}
+	return fmt.Errorf("no such path: %s", dir)
+}
+
+func (sh *shell) get(args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: get path")
+	}
+	value, err := sh.tree.plaintext(sh.resolve(args[0]))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(sh.term, "%s\n", value)
+	return nil
+}
+
+func (sh *shell) ls(args []string) error {
+	if len(args) > 1 {
+		return errors.New("Usage: ls [pattern]")
+	}
+
+	// paths are listed relative to the current path
+	sub := sh.tree.Empty()
+	for _, branch := range sh.tree.branches {
+		p := branch.path
+		if branch.val == nil || !p.IsUserData() || p == sh.cwd || !within(sh.cwd, p) {
+			continue // deleted branches linger until the tree's sorted
+		}
+		rel := strings.TrimPrefix(string(p), string(sh.cwd)+"/")
+		sub.set(NewPath(rel), branch.val)
+	}
+	if len(args) == 1 {
+		var err error
+		sub, err = sub.Filter(args[0])
+		if err != nil {
+			return err
+		}
+	}
+	return sub.Print(sh.term)
+}
+
+func (sh *shell) rm(args []string) error {
+	if len(args) == 0 {
+		return errors.New("Usage: rm path [path [...]]")
+	}
+	for _, arg := range args {
+		p := sh.resolve(arg)
+		if p == "" || !p.IsUserData() {
+			return fmt.Errorf("can't remove %q", arg)
+		}
+		if sh.tree.Delete(p) == 0 {
+			return fmt.Errorf("no such path: %s", p)
+		}
+		sh.changes++
+	}
+	return nil
+}
+
+func (sh *shell) save() error {
+	if sh.changes == 0 {
+		io.WriteString(sh.term, "nothing to save\n")
+		return nil
+	}
+	if err := sh.tree.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(sh.term, "saved %d changes\n", sh.changes)
+	sh.changes = 0
+	return nil
+}
+
+// set prompts for a value rather than taking it as an argument so
+// that values never appear in the shell's history.
+func (sh *shell) set(args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: set path  (hush prompts for the value)")
+	}
+	p := sh.resolve(args[0])
+	if err := checkSettable(p); err != nil {
+		return err
+	}
+	value, err := sh.term.ReadPassword("Value: ")
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return errors.New("empty value. nothing set")
+	}
+	sh.tree.setLeaf(p, NewPlaintext([]byte(value), Private))
+	sh.tree.Touch(p)
+	sh.changes++
+	return nil
+}
+
+// complete implements tab completion of command names and paths.
+func (sh *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	start := strings.LastIndex(line[:pos], " ") + 1
+	word := line[start:pos]
+
+	var candidates []string
+	if strings.TrimSpace(line[:start]) == "" {
+		for _, command := range shellCommands {
+			if strings.HasPrefix(command, word) {
+				candidates = append(candidates, command+" ")
+			}
+		}
+	} else {
+		// complete relative to the current path
+		dirPart := ""
+		if i := strings.LastIndex(word, "/"); i >= 0 {
+			dirPart = word[:i+1]
+		}
+		dir := sh.resolve(dirPart)
+		for _, name := range sh.tree.children(dir) {
+			if strings.HasPrefix(name, word[len(dirPart):]) {
+				candidates = append(candidates, dirPart+name)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	completion := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, completion) {
+			completion = completion[:len(completion)-1]
+		}
+	}
+	if len(candidates) > 1 && completion == word {
+		fmt.Fprintf(sh.term, "%s\n", strings.Join(candidates, "  "))
+		return "", 0, false
+	}
+	return line[:start] + completion + line[pos:], start + len(completion), true
+}
@@ -0,0 +1,85 @@
+package hush
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+func TestShellRemoveThenSet(t *testing.T) {
+	useTempHushFile(t)
+	var out bytes.Buffer
+	input := strings.NewReader("hunter2\r")
+	sh := &shell{tree: newTestTree()}
+	sh.term = terminal.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{input, &out}, "")
+
+	if err := sh.run([]string{"rm", "paypal.com"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, child := range sh.tree.children("") {
+		if child == "" {
+			t.Errorf("deleted branches shouldn't be offered as children")
+		}
+	}
+
+	commands := [][]string{
+		{"save"},
+		{"set", "example.com/password"},
+		{"save"},
+	}
+	for _, command := range commands {
+		if err := sh.run(command); err != nil {
+			t.Fatalf("%s: %s", command, err)
+		}
+	}
+	saved, err := LoadTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := saved.get(NewPath("example.com/password")); !ok {
+		t.Errorf("example.com/password is missing from the hush file")
+	}
+	if _, ok := saved.get(NewPath("paypal.com/personal/password")); ok {
+		t.Errorf("paypal.com/personal/password should be gone from the hush file")
+	}
+}
+
+func TestShellEndOfInput(t *testing.T) {
+	filename := useTempHushFile(t)
+	var out bytes.Buffer
+	input := strings.NewReader("hunter2\r")
+	sh := &shell{tree: newTestTree()}
+	sh.term = terminal.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{input, &out}, "")
+	if err := sh.run([]string{"set", "example.com/password"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// unsaved changes can't be confirmed, so they're discarded
+	done := make(chan error, 1)
+	go func() { done <- sh.loop() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("end of input: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shell kept running after its input ended")
+	}
+	if !strings.Contains(out.String(), "discarding 1 unsaved changes") {
+		t.Errorf("missing warning: %q", out.String())
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("unsaved changes were written to the hush file")
+	}
+}
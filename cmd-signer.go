@@ -0,0 +1,46 @@
+package hush
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CmdSigner manages the writers this user trusts to sign hush files.
+// args holds the subcommand and its arguments.  The list of signers
+// is kept outside the hush file, so it needs no password.
+//
+// This function implements "hush signer"
+func CmdSigner(w io.Writer, args []string) error {
+	usage := errors.New("Usage: hush signer add|rm|ls [name [public-key]]")
+	if len(args) < 1 {
+		return usage
+	}
+
+	switch args[0] {
+	case "ls":
+		if len(args) != 1 {
+			return usage
+		}
+		signers, err := readSigners()
+		if err != nil {
+			return err
+		}
+		for _, signer := range signers {
+			fmt.Fprintf(w, "%s\t%s\n", signer.name, authorizedKey(signer.key))
+		}
+		return nil
+	case "add":
+		if len(args) < 3 {
+			return usage
+		}
+		return AddSigner(args[1], strings.Join(args[2:], " "))
+	case "rm":
+		if len(args) != 2 {
+			return usage
+		}
+		return RemoveSigner(args[1])
+	}
+	return usage
+}
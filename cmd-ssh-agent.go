@@ -0,0 +1,406 @@
+package hush
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// sshAgent is an SSH agent which keeps keys in memory.  Keys with a
+// confirmation constraint are only used after the user agrees.
+type sshAgent struct {
+	agent.ExtendedAgent
+
+	mu       sync.Mutex        // guards confirm and serializes prompts
+	confirm  map[string]string // comments of keys needing confirmation, by public key
+	detached bool              // true if there's no terminal to confirm on
+}
+
+// errNoConfirm explains why a detached agent can't hold a key which
+// needs confirmation.
+var errNoConfirm = errors.New("confirming use of this key needs SSH_ASKPASS or --foreground")
+
+// CmdSshAgent loads the SSH keys stored beneath prefix in tree and
+// serves them with the SSH agent protocol on a Unix socket.  If socket
+// is empty, one is created in a private temporary directory.  Like
+// ssh-agent, it prints shell commands to w which point SSH_AUTH_SOCK
+// at the socket.  Unless foreground is true, the agent runs in a
+// detached process so the output can be given to eval.  Keys are never
+// written to disk.
+//
+// This function implements "hush ssh-agent"
+func CmdSshAgent(w io.Writer, tree *Tree, prefix, socket string, foreground bool) error {
+	keys, err := sshKeys(tree, NewPath(prefix))
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no SSH keys beneath %s", prefix)
+	}
+
+	if foreground {
+		return serveSshAgent(keys, socket, false, func(socket string) {
+			fmt.Fprintf(w, "SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK;\n", socket)
+			fmt.Fprintf(w, "# %d keys loaded. Interrupt to stop.\n", len(keys))
+		})
+	}
+
+	// a detached agent has no terminal, so fail now rather than
+	// refusing every use of the key later
+	if os.Getenv("SSH_ASKPASS") == "" {
+		for _, key := range keys {
+			if key.ConfirmBeforeUse {
+				return fmt.Errorf("%s: %s", key.Comment, errNoConfirm)
+			}
+		}
+	}
+
+	// pass the keys to a detached agent through a pipe
+	data, err := marshalAgentKeys(keys)
+	if err != nil {
+		return err
+	}
+	defer wipe(data)
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "__ssh-agent", socket)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	stdin.Write(data)
+	stdin.Close()
+
+	// the agent reports its socket once it's listening
+	line, _ := bufio.NewReader(stdout).ReadString('\n')
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "ok ") {
+		cmd.Wait()
+		if line == "" {
+			line = "agent exited"
+		}
+		return errors.New(line)
+	}
+	socket = strings.TrimPrefix(line, "ok ")
+	fmt.Fprintf(w, "SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK;\n", socket)
+	fmt.Fprintf(w, "SSH_AGENT_PID=%d; export SSH_AGENT_PID;\n", cmd.Process.Pid)
+	fmt.Fprintf(w, "echo Agent pid %d;\n", cmd.Process.Pid)
+	return cmd.Process.Release()
+}
+
+// SshAgentServe runs a detached SSH agent with the keys read from r,
+// as marshaled by CmdSshAgent.  Once the agent is listening on socket,
+// "ok" and the socket's name are written to w.
+//
+// This function implements the hidden "hush __ssh-agent"
+func SshAgentServe(r io.Reader, w io.Writer, socket string) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	keys, err := unmarshalAgentKeys(data)
+	wipe(data)
+	if err != nil {
+		return err
+	}
+	return serveSshAgent(keys, socket, true, func(socket string) {
+		fmt.Fprintf(w, "ok %s\n", socket)
+	})
+}
+
+// serveSshAgent serves keys on socket until it's stopped by a signal.
+// detached is true if the agent has no terminal.  ready is called with
+// the socket's name once it's listening.
+func serveSshAgent(keys []agent.AddedKey, socket string, detached bool, ready func(string)) error {
+	a := &sshAgent{
+		ExtendedAgent: agent.NewKeyring().(agent.ExtendedAgent),
+		confirm:       make(map[string]string),
+		detached:      detached,
+	}
+	for _, key := range keys {
+		if err := a.Add(key); err != nil {
+			return fmt.Errorf("%s: %s", key.Comment, err)
+		}
+	}
+
+	cleanup := func() {}
+	if socket == "" {
+		dir, err := ioutil.TempDir("", "hush-ssh-")
+		if err != nil {
+			return err
+		}
+		socket = filepath.Join(dir, "agent.sock")
+		cleanup = func() { os.RemoveAll(dir) }
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		cleanup()
+		return err
+	}
+	if err := os.Chmod(socket, safePerm); err != nil {
+		l.Close()
+		cleanup()
+		return err
+	}
+
+	// remove the socket when we're stopped
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		<-signals
+		os.Remove(socket)
+		cleanup()
+		os.Exit(0)
+	}()
+
+	ready(socket)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			agent.ServeAgent(a, conn)
+		}()
+	}
+}
+
+// agentKey is how a key travels to a detached agent.
+type agentKey struct {
+	PrivateKey   []byte // OpenSSH private key, without a passphrase
+	Comment      string
+	Confirm      bool
+	LifetimeSecs uint32
+}
+
+// marshalAgentKeys encodes keys for a detached agent.
+func marshalAgentKeys(keys []agent.AddedKey) ([]byte, error) {
+	var encoded []agentKey
+	for _, key := range keys {
+		private := key.PrivateKey
+		if k, ok := private.(*ed25519.PrivateKey); ok {
+			private = *k // MarshalPrivateKey wants the value
+		}
+		block, err := ssh.MarshalPrivateKey(private, key.Comment)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", key.Comment, err)
+		}
+		encoded = append(encoded, agentKey{
+			PrivateKey:   pem.EncodeToMemory(block),
+			Comment:      key.Comment,
+			Confirm:      key.ConfirmBeforeUse,
+			LifetimeSecs: key.LifetimeSecs,
+		})
+	}
+	return json.Marshal(encoded)
+}
+
+// unmarshalAgentKeys decodes keys encoded by marshalAgentKeys.
+func unmarshalAgentKeys(data []byte) ([]agent.AddedKey, error) {
+	var encoded []agentKey
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, err
+	}
+	var keys []agent.AddedKey
+	for _, k := range encoded {
+		private, err := ssh.ParseRawPrivateKey(k.PrivateKey)
+		wipe(k.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", k.Comment, err)
+		}
+		keys = append(keys, agent.AddedKey{
+			PrivateKey:       private,
+			Comment:          k.Comment,
+			ConfirmBeforeUse: k.Confirm,
+			LifetimeSecs:     k.LifetimeSecs,
+		})
+	}
+	return keys, nil
+}
+
+// sshKeys parses the private keys stored beneath prefix.  Each key is
+// in its own subtree, with these leaves:
+//
+//	private-key  a PEM or OpenSSH private key
+//	passphrase   the key's passphrase, if it has one
+//	confirm      "true" to ask before each use of the key
+//	lifetime     how long the agent keeps the key, like "1h"
+//
+// Only private-key is required.  Each key's comment is the path of its
+// subtree.
+func sshKeys(tree *Tree, prefix Path) ([]agent.AddedKey, error) {
+	leaf := func(dir Path, name string) (string, error) {
+		p := NewPath(dir.String() + "/" + name)
+		if _, ok := tree.get(p); !ok {
+			return "", nil
+		}
+		return tree.plaintext(p)
+	}
+
+	var keys []agent.AddedKey
+	for _, branch := range tree.branches {
+		p := branch.path
+		if !p.IsUserData() || !prefix.HasDescendant(p) ||
+			!strings.HasSuffix(string(p), "/private-key") {
+			continue
+		}
+		dir := p.Parent()
+		pem, err := tree.plaintext(p)
+		if err != nil {
+			return nil, err
+		}
+		passphrase, err := leaf(dir, "passphrase")
+		if err != nil {
+			return nil, err
+		}
+		var key interface{}
+		if passphrase == "" {
+			key, err = ssh.ParseRawPrivateKey([]byte(pem))
+		} else {
+			key, err = ssh.ParseRawPrivateKeyWithPassphrase([]byte(pem), []byte(passphrase))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", p, err)
+		}
+
+		added := agent.AddedKey{PrivateKey: key, Comment: dir.String()}
+		confirm, err := leaf(dir, "confirm")
+		if err != nil {
+			return nil, err
+		}
+		if confirm != "" {
+			added.ConfirmBeforeUse, err = strconv.ParseBool(strings.TrimSpace(confirm))
+			if err != nil {
+				return nil, fmt.Errorf("%s/confirm: want true or false", dir)
+			}
+		}
+		lifetime, err := leaf(dir, "lifetime")
+		if err != nil {
+			return nil, err
+		}
+		if lifetime != "" {
+			d, err := parseLifetime(strings.TrimSpace(lifetime))
+			if err != nil {
+				return nil, fmt.Errorf("%s/lifetime: %s", dir, err)
+			}
+			added.LifetimeSecs = uint32(d / time.Second)
+		}
+		keys = append(keys, added)
+	}
+	return keys, nil
+}
+
+// parseLifetime parses a duration like "1h".  A plain number is
+// seconds.  The agent protocol can't express more than MaxUint32
+// seconds.
+func parseLifetime(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if n, nerr := strconv.ParseInt(s, 10, 64); nerr == nil {
+		if n > math.MaxUint32 {
+			return 0, fmt.Errorf("lifetime too long: %s", s)
+		}
+		d, err = time.Duration(n)*time.Second, nil
+	}
+	if err != nil || d < time.Second {
+		return 0, fmt.Errorf("invalid lifetime: %s", s)
+	}
+	if d/time.Second > math.MaxUint32 {
+		return 0, fmt.Errorf("lifetime too long: %s", s)
+	}
+	return d, nil
+}
+
+// Add adds a key, remembering whether it needs confirmation.  Keys
+// added by clients, as with "ssh-add -c", are handled the same way.  A
+// detached agent refuses keys it couldn't confirm.
+func (a *sshAgent) Add(key agent.AddedKey) error {
+	if key.ConfirmBeforeUse && a.detached && os.Getenv("SSH_ASKPASS") == "" {
+		return errNoConfirm
+	}
+	if err := a.ExtendedAgent.Add(key); err != nil {
+		return err
+	}
+	signer, err := ssh.NewSignerFromKey(key.PrivateKey)
+	if err != nil {
+		return err
+	}
+	id := string(signer.PublicKey().Marshal())
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if key.ConfirmBeforeUse {
+		a.confirm[id] = key.Comment
+	} else {
+		delete(a.confirm, id)
+	}
+	return nil
+}
+
+func (a *sshAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return a.SignWithFlags(key, data, 0)
+}
+
+func (a *sshAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	a.mu.Lock()
+	comment, ok := a.confirm[string(key.Marshal())]
+	if ok && !confirmUse(comment, ssh.FingerprintSHA256(key)) {
+		a.mu.Unlock()
+		return nil, errors.New("agent: user refused to use key")
+	}
+	a.mu.Unlock()
+	return a.ExtendedAgent.SignWithFlags(key, data, flags)
+}
+
+// confirmUse asks the user whether the key described by comment and
+// fingerprint may be used.  Like ssh-agent, it runs SSH_ASKPASS if
+// it's set.  Otherwise, it asks on the agent's terminal.  If neither
+// is possible, the answer is no, though a detached agent refuses such
+// keys when they're added.
+func confirmUse(comment, fingerprint string) bool {
+	prompt := fmt.Sprintf("Allow use of key %s?\nKey fingerprint %s.", comment, fingerprint)
+	if askpass := os.Getenv("SSH_ASKPASS"); askpass != "" {
+		cmd := exec.Command(askpass, prompt)
+		cmd.Env = append(os.Environ(), "SSH_ASKPASS_PROMPT=confirm")
+		return cmd.Run() == nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+	fmt.Fprintf(tty, "%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(tty).ReadBytes('\n')
+	answer = bytes.ToLower(bytes.TrimSpace(answer))
+	return string(answer) == "y" || string(answer) == "yes"
+}
@@ -0,0 +1,192 @@
+package hush
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestParseLifetime(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Duration
+		ok   bool
+	}{
+		{"3600", time.Hour, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"4294967295", 4294967295 * time.Second, true},
+		{"4294967296", 0, false},
+		{"2000000h", 0, false},
+		{"0", 0, false},
+		{"-5", 0, false},
+		{"500ms", 0, false},
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		got, err := parseLifetime(test.s)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("parseLifetime(%q) = %s, %v", test.s, got, err)
+		}
+	}
+}
+
+// testSSHKey returns a new ed25519 key and its OpenSSH PEM encoding.
+func testSSHKey(t *testing.T, passphrase string) (ssh.PublicKey, string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(private, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(private, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, string(pem.EncodeToMemory(block))
+}
+
+func TestSshKeys(t *testing.T) {
+	tree := newTestTree()
+	leaves := map[string]string{}
+	_, leaves["ssh/plain/private-key"] = testSSHKey(t, "")
+	_, leaves["ssh/locked/private-key"] = testSSHKey(t, "sesame")
+	leaves["ssh/locked/passphrase"] = "sesame"
+	leaves["ssh/locked/confirm"] = "true"
+	leaves["ssh/locked/lifetime"] = "1h"
+	_, leaves["other/private-key"] = testSSHKey(t, "")
+	for p, v := range leaves {
+		tree.set(NewPath(p), NewPlaintext([]byte(v), Private))
+	}
+
+	keys, err := sshKeys(tree, NewPath("ssh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]agent.AddedKey)
+	for _, key := range keys {
+		got[key.Comment] = key
+	}
+	if len(got) != 2 {
+		t.Fatalf("want 2 keys beneath ssh, got %d", len(keys))
+	}
+	if key := got["ssh/plain"]; key.ConfirmBeforeUse || key.LifetimeSecs != 0 {
+		t.Errorf("ssh/plain has constraints: %+v", key)
+	}
+	if key := got["ssh/locked"]; !key.ConfirmBeforeUse || key.LifetimeSecs != 3600 {
+		t.Errorf("ssh/locked has wrong constraints: %+v", key)
+	}
+
+	// keys survive the trip to a detached agent
+	data, err := marshalAgentKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err = unmarshalAgentKeys(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if want := got[key.Comment]; key.ConfirmBeforeUse != want.ConfirmBeforeUse || key.LifetimeSecs != want.LifetimeSecs {
+			t.Errorf("%s: constraints changed in transit", key.Comment)
+		}
+	}
+
+	tree.set(NewPath("ssh/locked/passphrase"), NewPlaintext([]byte("wrong"), Private))
+	if _, err := sshKeys(tree, NewPath("ssh")); err == nil {
+		t.Errorf("wrong passphrase should fail")
+	}
+}
+
+func TestSshAgentConfirm(t *testing.T) {
+	a := &sshAgent{
+		ExtendedAgent: agent.NewKeyring().(agent.ExtendedAgent),
+		confirm:       make(map[string]string),
+	}
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := signer.PublicKey()
+	key := agent.AddedKey{PrivateKey: private, Comment: "test", ConfirmBeforeUse: true}
+	if err := a.Add(key); err != nil {
+		t.Fatal(err)
+	}
+	if a.confirm[string(pub.Marshal())] != "test" {
+		t.Errorf("confirmation wasn't recorded")
+	}
+
+	setenv(t, "SSH_ASKPASS", "false")
+	if _, err := a.Sign(pub, []byte("data")); err == nil {
+		t.Errorf("signed although the user refused")
+	}
+	setenv(t, "SSH_ASKPASS", "true")
+	if _, err := a.SignWithFlags(pub, []byte("data"), 0); err != nil {
+		t.Errorf("user agreed but signing failed: %s", err)
+	}
+
+	// adding the key again without the constraint drops it
+	key.ConfirmBeforeUse = false
+	if err := a.Add(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.confirm[string(pub.Marshal())]; ok {
+		t.Errorf("confirmation should have been dropped")
+	}
+	setenv(t, "SSH_ASKPASS", "false")
+	if _, err := a.Sign(pub, []byte("data")); err != nil {
+		t.Errorf("unconstrained key needs no confirmation: %s", err)
+	}
+}
+
+func TestSshAgentDetachedConfirm(t *testing.T) {
+	tree := newTestTree()
+	_, private := testSSHKey(t, "")
+	tree.set(NewPath("ssh/github/private-key"), NewPlaintext([]byte(private), Private))
+	tree.set(NewPath("ssh/github/confirm"), NewPlaintext([]byte("true"), Private))
+
+	// fails before starting an agent which could never confirm
+	setenv(t, "SSH_ASKPASS", "")
+	var out bytes.Buffer
+	if err := CmdSshAgent(&out, tree, "ssh", "", false); err == nil {
+		t.Errorf("detached agent accepted a confirm key without SSH_ASKPASS")
+	}
+	if out.Len() > 0 {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+
+	// keys added by clients are refused too
+	a := &sshAgent{
+		ExtendedAgent: agent.NewKeyring().(agent.ExtendedAgent),
+		confirm:       make(map[string]string),
+		detached:      true,
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added := agent.AddedKey{PrivateKey: key, Comment: "test", ConfirmBeforeUse: true}
+	if err := a.Add(added); err == nil {
+		t.Errorf("detached agent accepted 'ssh-add -c' without SSH_ASKPASS")
+	}
+	setenv(t, "SSH_ASKPASS", "true")
+	if err := a.Add(added); err != nil {
+		t.Errorf("with SSH_ASKPASS: %s", err)
+	}
+}
@@ -0,0 +1,55 @@
+package hush
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// CmdTemplate renders the template in filename against tree.  The
+// result replaces output, a file with 0600 permissions, or goes to
+// w if output is empty.  If check is true, it only verifies that the
+// paths the template refers to exist, which doesn't need an unlocked
+// tree.
+//
+// This function implements "hush template"
+func CmdTemplate(w io.Writer, tree *Tree, filename, output string, check bool) error {
+	tmpl, err := parseTemplate(tree, filename, check)
+	if err != nil {
+		return err
+	}
+
+	// render completely first so errors don't yield partial files
+	var buf bytes.Buffer
+	defer func() { wipe(buf.Bytes()) }()
+	err = tmpl.Execute(&buf, nil)
+	if err != nil || check {
+		return err
+	}
+
+	if output == "" {
+		_, err = w.Write(buf.Bytes())
+		return err
+	}
+
+	// write a private temporary file then move it over output, so
+	// secrets never land in a file others can read
+	file, err := ioutil.TempFile(filepath.Dir(output), ".hush-template-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // in case we fail before renaming
+	err = file.Chmod(safePerm)
+	if err == nil {
+		_, err = file.Write(buf.Bytes())
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), output)
+}
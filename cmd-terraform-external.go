@@ -0,0 +1,60 @@
+package hush
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// terraformQuery is the query Terraform's external data source sends
+// on stdin.
+type terraformQuery struct {
+	Path string `json:"path"`
+}
+
+// CmdTerraformExternal answers a query from Terraform's external data
+// source.  It reads a JSON object with a "path" key from r.  If the
+// path names a leaf, writes its value to w as {"value": ...}.
+// Otherwise, writes every leaf beneath the path keyed by its path
+// relative to the query.
+//
+// This function implements "hush terraform-external"
+func CmdTerraformExternal(w io.Writer, r io.Reader, tree *Tree) error {
+	var query terraformQuery
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&query); err != nil {
+		return fmt.Errorf("query: %s", err)
+	}
+	if query.Path == "" {
+		return errors.New(`query needs a "path"`)
+	}
+
+	result := make(map[string]string)
+	p := NewPath(strings.Trim(query.Path, "/"))
+	if _, ok := tree.get(p); ok && p.IsUserData() {
+		value, err := tree.plaintext(p)
+		if err != nil {
+			return err
+		}
+		result["value"] = value
+	} else {
+		for _, branch := range tree.branches {
+			leaf := branch.path
+			if !leaf.IsUserData() || !p.HasDescendant(leaf) {
+				continue
+			}
+			value, err := tree.plaintext(leaf)
+			if err != nil {
+				return err
+			}
+			result[strings.TrimPrefix(string(leaf), string(p)+"/")] = value
+		}
+		if len(result) == 0 {
+			return fmt.Errorf("no such path: %s", p)
+		}
+	}
+	return json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,54 @@
+package hush
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTerraformExternal(t *testing.T) {
+	tree := newTestTree()
+	tree.set(NewPath("paypal.com/personal/username"), NewPlaintext([]byte("bob"), Private))
+	query := func(input string) (map[string]string, error) {
+		var out bytes.Buffer
+		if err := CmdTerraformExternal(&out, strings.NewReader(input), tree); err != nil {
+			return nil, err
+		}
+		var result map[string]string
+		if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+			t.Fatalf("%s: invalid response %q", input, out.String())
+		}
+		return result, nil
+	}
+
+	tests := []struct {
+		query string
+		want  map[string]string
+	}{
+		{`{"path":"paypal.com/personal/password"}`, map[string]string{"value": "secret"}},
+		{`{"path":"/paypal.com/personal/password/"}`, map[string]string{"value": "secret"}},
+		{`{"path":"paypal.com"}`, map[string]string{"personal/password": "secret", "personal/username": "bob"}},
+	}
+	for _, test := range tests {
+		got, err := query(test.query)
+		if err != nil {
+			t.Errorf("%s: %s", test.query, err)
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %q", test.query, got)
+		}
+		for k, v := range test.want {
+			if got[k] != v {
+				t.Errorf("%s: %s = %q, want %q", test.query, k, got[k], v)
+			}
+		}
+	}
+
+	for _, input := range []string{`{}`, `{"path":"nothing/here"}`, `{"path":"a","extra":"b"}`, `not json`, `{"path":"hush-configuration"}`} {
+		if _, err := query(input); err == nil {
+			t.Errorf("%s should fail", input)
+		}
+	}
+}
@@ -0,0 +1,653 @@
+package hush
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// tui is the state of the full-screen terminal interface.  Edits
+// accumulate in tree until the user saves them.
+type tui struct {
+	tree    *Tree
+	tty     *tty
+	keys    chan keyEvent
+	idle    time.Duration // lock after this long without a key press
+	changes int           // unsaved edits
+
+	leaves   []Path // every leaf, sorted
+	visible  []Path // leaves matching query
+	query    string
+	selected int // index into visible
+	revealed bool
+	message  string
+}
+
+type keyEvent struct {
+	key  key
+	r    rune
+	err  error
+	idle bool // no key was pressed before the idle timeout
+}
+
+// tuiRow is one line of the tree pane.  Rows for interior nodes have
+// no leaf.
+type tuiRow struct {
+	text  string
+	depth int
+	leaf  Path
+}
+
+const tuiHelp = "/ search  r reveal  c copy  a add  m rename  d delete  g generate  s save  q quit"
+
+var errTuiQuit = errors.New("quit")
+
+// CmdTui runs a full-screen interface for browsing and editing tree.
+// After idle without a key press, the tree's keys are wiped from
+// memory until the user unlocks it again.  An idle of zero never
+// locks.
+//
+// This function implements "hush tui"
+func CmdTui(tree *Tree, idle time.Duration) error {
+	u := &tui{tree: tree, idle: idle}
+	u.refresh()
+	if err := u.open(); err != nil {
+		return err
+	}
+	defer func() {
+		if u.tty != nil {
+			u.close()
+		}
+	}()
+
+	for {
+		u.draw("")
+		ev := u.next()
+		if ev.err != nil {
+			return ev.err
+		}
+		var err error
+		if ev.idle {
+			err = u.lock()
+		} else {
+			err = u.handle(ev)
+		}
+		if err == errTuiQuit {
+			return nil
+		}
+		if err != nil && u.tty == nil {
+			return err // lost the terminal while locked
+		}
+		if err != nil {
+			u.message = err.Error()
+		}
+	}
+}
+
+// open takes over the terminal.
+func (u *tui) open() error {
+	t, err := openTTY()
+	if err != nil {
+		return err
+	}
+	u.tty = t
+	u.tty.Printf("\x1b[?1049h\x1b[?25l") // alternate screen, hide cursor
+
+	keys := make(chan keyEvent, 16)
+	go func() {
+		for {
+			k, r, err := t.ReadKey()
+			keys <- keyEvent{key: k, r: r, err: err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	u.keys = keys
+	return nil
+}
+
+// close gives the terminal back.
+func (u *tui) close() {
+	u.tty.Printf("\x1b[?25h\x1b[?1049l")
+	u.tty.Close()
+	u.tty = nil
+}
+
+// next waits for a key press or the idle timeout.
+func (u *tui) next() keyEvent {
+	if u.idle == 0 {
+		return <-u.keys
+	}
+	select {
+	case ev := <-u.keys:
+		return ev
+	case <-time.After(u.idle):
+		return keyEvent{idle: true}
+	}
+}
+
+// refresh rebuilds the list of leaves after the tree changes.
+func (u *tui) refresh() {
+	u.tree.Sort()
+	u.leaves = u.leaves[:0]
+	for _, branch := range u.tree.branches {
+		if branch.path.IsUserData() {
+			u.leaves = append(u.leaves, branch.path)
+		}
+	}
+	u.filter()
+}
+
+// filter selects the leaves which match the search query.
+func (u *tui) filter() {
+	u.visible = u.visible[:0]
+	for _, p := range u.leaves {
+		if _, ok := fuzzyScore(p, u.query); ok {
+			u.visible = append(u.visible, p)
+		}
+	}
+	if u.selected >= len(u.visible) {
+		u.selected = len(u.visible) - 1
+	}
+	if u.selected < 0 {
+		u.selected = 0
+	}
+}
+
+// current returns the selected leaf, if any.
+func (u *tui) current() (Path, bool) {
+	if len(u.visible) == 0 {
+		return "", false
+	}
+	return u.visible[u.selected], true
+}
+
+// selectPath moves the selection to p, if it's visible.
+func (u *tui) selectPath(p Path) {
+	for i, v := range u.visible {
+		if v == p {
+			u.selected = i
+		}
+	}
+}
+
+// rows lays out the visible leaves as a hierarchy.  Returns the rows
+// and the index of the selected leaf's row.
+func (u *tui) rows() ([]tuiRow, int) {
+	var rows []tuiRow
+	var prev []string
+	selected := 0
+	for i, p := range u.visible {
+		crumbs := p.AsCrumbs()
+		n := len(crumbs) - 1
+		common := 0
+		for common < n && common < len(prev)-1 && prev[common] == crumbs[common] {
+			common++
+		}
+		for d := common; d < n; d++ {
+			rows = append(rows, tuiRow{text: crumbs[d] + "/", depth: d})
+		}
+		if i == u.selected {
+			selected = len(rows)
+		}
+		rows = append(rows, tuiRow{text: crumbs[n], depth: n, leaf: p})
+		prev = crumbs
+	}
+	return rows, selected
+}
+
+// draw paints the whole screen.  status replaces the usual status
+// line.
+func (u *tui) draw(status string) {
+	width, height := u.tty.Size()
+	left := width * 2 / 5
+	right := width - left - 3
+	body := height - 2
+
+	var b bytes.Buffer
+	b.WriteString("\x1b[H\x1b[2J")
+	title := fmt.Sprintf("hush: %d leaves", len(u.leaves))
+	if u.changes > 0 {
+		title += fmt.Sprintf(", %d unsaved changes", u.changes)
+	}
+	if u.query != "" {
+		title += "  search: " + u.query
+	}
+	b.WriteString("\x1b[1m" + fit(title, width) + "\x1b[0m")
+
+	rows, selected := u.rows()
+	offset := 0
+	if selected >= body {
+		offset = selected - body + 1
+	}
+	details := u.details()
+	for i := 0; i < body; i++ {
+		b.WriteString("\r\n")
+		if r := offset + i; r < len(rows) {
+			row := rows[r]
+			text := fit(strings.Repeat("  ", row.depth)+row.text, left)
+			if r == selected && row.leaf != "" {
+				text = "\x1b[7m" + text + "\x1b[0m"
+			}
+			b.WriteString(text)
+		} else {
+			b.WriteString(strings.Repeat(" ", left))
+		}
+		b.WriteString(" │ ")
+		if i < len(details) {
+			b.WriteString(fit(details[i], right))
+		}
+	}
+
+	if status == "" {
+		status = u.message
+		u.message = ""
+	}
+	if status == "" {
+		status = tuiHelp
+	}
+	b.WriteString("\r\n" + fit(status, width))
+	u.tty.Write(b.Bytes())
+}
+
+// details returns the lines describing the selected leaf.
+func (u *tui) details() []string {
+	p, ok := u.current()
+	if !ok {
+		return []string{"no matching leaves"}
+	}
+	lines := []string{p.String(), ""}
+	value := "******** (r to reveal)"
+	if u.revealed {
+		v, err := u.tree.plaintext(p)
+		if err != nil {
+			value = err.Error()
+		} else {
+			value = v
+		}
+	}
+	lines = append(lines, "value: "+value)
+
+	meta, err := u.tree.Metadata(p)
+	if err != nil {
+		return append(lines, err.Error())
+	}
+	for _, field := range metadataFields {
+		if meta[field] != "" {
+			lines = append(lines, field+": "+meta[field])
+		}
+	}
+	return lines
+}
+
+// fit pads or truncates s to exactly width characters.
+func fit(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:width])
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// handle responds to a key press.
+func (u *tui) handle(ev keyEvent) error {
+	k, r := ev.key, ev.r
+	if k == keyRune {
+		switch r {
+		case 'k':
+			k = keyUp
+		case 'j':
+			k = keyDown
+		case ' ':
+			k = keyEnter
+		}
+	}
+
+	switch k {
+	case keyUp:
+		if u.selected > 0 {
+			u.selected--
+		}
+		u.revealed = false
+		return nil
+	case keyDown:
+		if u.selected < len(u.visible)-1 {
+			u.selected++
+		}
+		u.revealed = false
+		return nil
+	case keyEnter:
+		u.revealed = !u.revealed
+		return nil
+	case keyEscape:
+		u.query = ""
+		u.filter()
+		return nil
+	case keyCtrlC, keyCtrlD:
+		return u.quit()
+	case keyRune:
+	default:
+		return nil
+	}
+
+	switch r {
+	case '/':
+		return u.search()
+	case 'r':
+		u.revealed = !u.revealed
+	case 'c':
+		return u.copy()
+	case 'a':
+		return u.add()
+	case 'm':
+		return u.rename()
+	case 'd':
+		return u.remove()
+	case 'g':
+		return u.generate()
+	case 's':
+		return u.save()
+	case 'q':
+		return u.quit()
+	}
+	return nil
+}
+
+// search filters the tree pane as the user types.
+func (u *tui) search() error {
+	for {
+		u.draw("/" + u.query)
+		ev := u.next()
+		if ev.err != nil {
+			return ev.err
+		}
+		if ev.idle {
+			return u.lock()
+		}
+		switch ev.key {
+		case keyRune:
+			u.query += string(ev.r)
+		case keyBackspace:
+			if u.query != "" {
+				_, size := utf8.DecodeLastRuneInString(u.query)
+				u.query = u.query[:len(u.query)-size]
+			}
+		case keyCtrlU:
+			u.query = ""
+		case keyEscape, keyCtrlC:
+			u.query = ""
+			u.filter()
+			return nil
+		case keyEnter, keyUp, keyDown:
+			return nil
+		}
+		u.selected = 0
+		u.filter()
+	}
+}
+
+// prompt asks the user for a line of text on the status line.  Hidden
+// text is shown as asterisks.  Returns false if the user cancels.
+func (u *tui) prompt(label, text string, hidden bool) (string, bool, error) {
+	for {
+		shown := text
+		if hidden {
+			shown = strings.Repeat("*", utf8.RuneCountInString(text))
+		}
+		u.draw(label + shown)
+		ev := u.next()
+		if ev.err != nil {
+			return "", false, ev.err
+		}
+		if ev.idle {
+			return "", false, u.lock()
+		}
+		switch ev.key {
+		case keyRune:
+			text += string(ev.r)
+		case keyBackspace:
+			if text != "" {
+				_, size := utf8.DecodeLastRuneInString(text)
+				text = text[:len(text)-size]
+			}
+		case keyCtrlU:
+			text = ""
+		case keyEnter:
+			return text, true, nil
+		case keyEscape, keyCtrlC:
+			return "", false, nil
+		}
+	}
+}
+
+// confirm asks the user a yes or no question.
+func (u *tui) confirm(question string) (bool, error) {
+	answer, ok, err := u.prompt(question+" [y/N] ", "", false)
+	if err != nil || !ok {
+		return false, err
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
+
+// copy puts the selected leaf's value on the clipboard.
+func (u *tui) copy() error {
+	p, ok := u.current()
+	if !ok {
+		return nil
+	}
+	value, err := u.tree.plaintext(p)
+	if err != nil {
+		return err
+	}
+	c, err := newClipboard("")
+	if err != nil {
+		return err
+	}
+	timeout, err := clipboardTimeout()
+	if err != nil {
+		return err
+	}
+	err = copyToClipboard(c, []byte(value), timeout)
+	if err != nil {
+		return err
+	}
+	u.message = "copied " + p.String()
+	return nil
+}
+
+// add creates a new leaf.
+func (u *tui) add() error {
+	prefix := ""
+	if p, ok := u.current(); ok {
+		prefix = p.Parent().String() + "/"
+	}
+	s, ok, err := u.prompt("new path: ", prefix, false)
+	if err != nil || !ok || s == "" {
+		return err
+	}
+	p := NewPath(s)
+	if err := checkSettable(p); err != nil {
+		return err
+	}
+	if _, exists := u.tree.get(p); exists {
+		return fmt.Errorf("leaf already exists: %s", p)
+	}
+
+	value, ok, err := u.prompt("value (empty to generate): ", "", true)
+	if err != nil || !ok {
+		return err
+	}
+	if value == "" {
+		value, err = generatePassword(20)
+		if err != nil {
+			return err
+		}
+	}
+	u.tree.setLeaf(p, NewPlaintext([]byte(value), Private))
+	u.tree.Touch(p)
+	u.changes++
+	u.refresh()
+	u.selectPath(p)
+	u.message = "added " + p.String()
+	return nil
+}
+
+// rename moves the selected leaf.
+func (u *tui) rename() error {
+	from, ok := u.current()
+	if !ok {
+		return nil
+	}
+	s, ok, err := u.prompt("rename to: ", from.String(), false)
+	if err != nil || !ok || s == "" {
+		return err
+	}
+	to := NewPath(s)
+	if to == from {
+		return nil
+	}
+	if err := checkSettable(to); err != nil {
+		return err
+	}
+	if err := u.tree.Rename(from, to); err != nil {
+		return err
+	}
+	u.tree.Touch(to)
+	u.changes++
+	u.refresh()
+	u.selectPath(to)
+	u.message = "renamed to " + to.String()
+	return nil
+}
+
+// remove deletes the selected leaf.
+func (u *tui) remove() error {
+	p, ok := u.current()
+	if !ok {
+		return nil
+	}
+	yes, err := u.confirm("delete " + p.String() + "?")
+	if err != nil || !yes {
+		return err
+	}
+	u.tree.Delete(p)
+	u.changes++
+	u.refresh()
+	u.message = "deleted " + p.String()
+	return nil
+}
+
+// generate replaces the selected leaf's value with a random password.
+func (u *tui) generate() error {
+	p, ok := u.current()
+	if !ok {
+		return nil
+	}
+	yes, err := u.confirm("replace " + p.String() + " with a generated password?")
+	if err != nil || !yes {
+		return err
+	}
+	value, err := generatePassword(20)
+	if err != nil {
+		return err
+	}
+	u.tree.setLeaf(p, NewPlaintext([]byte(value), Private))
+	u.tree.Touch(p)
+	u.changes++
+	u.message = "generated a new value for " + p.String()
+	return nil
+}
+
+// save writes all edits to the hush file.
+func (u *tui) save() error {
+	if u.changes == 0 {
+		u.message = "nothing to save"
+		return nil
+	}
+	yes, err := u.confirm(fmt.Sprintf("save %d changes?", u.changes))
+	if err != nil || !yes {
+		return err
+	}
+	if err := u.tree.Save(); err != nil {
+		return err
+	}
+	u.changes = 0
+	u.message = "saved"
+	return nil
+}
+
+// quit leaves the interface, confirming first if there are unsaved
+// edits.
+func (u *tui) quit() error {
+	if u.changes > 0 {
+		yes, err := u.confirm(fmt.Sprintf("discard %d unsaved changes?", u.changes))
+		if err != nil || !yes {
+			return err
+		}
+	}
+	return errTuiQuit
+}
+
+// lock wipes the tree's keys from memory and waits for the user to
+// unlock it again.  Unsaved edits are encrypted first so they survive.
+// A wrong password returns to the lock screen, so it can't lose edits.
+func (u *tui) lock() error {
+	fingerprint := sha256.Sum256(u.tree.encryptionKey)
+	u.tree = u.tree.Encrypt()
+	u.tree.Lock()
+	u.revealed = false
+
+	message := ""
+	for {
+		u.tty.Printf("\x1b[H\x1b[2J")
+		u.tty.Printf("hush is locked after %s without activity.\r\n", u.idle)
+		if message != "" {
+			u.tty.Printf("%s\r\n", message)
+		}
+		u.tty.Printf("Press any key to unlock or q to quit.\r\n")
+		ev := <-u.keys
+		if ev.err != nil {
+			return ev.err
+		}
+		if ev.key == keyRune && ev.r == 'q' {
+			if u.changes == 0 {
+				return errTuiQuit
+			}
+			u.tty.Printf("Discard %d unsaved changes? [y/N] ", u.changes)
+			ev = <-u.keys
+			if ev.err != nil {
+				return ev.err
+			}
+			if ev.key == keyRune && (ev.r == 'y' || ev.r == 'Y') {
+				return errTuiQuit
+			}
+			message = ""
+			continue
+		}
+
+		// unlock with the usual prompts on a normal terminal
+		u.close()
+		fresh, err := unlockTree()
+		if err != nil {
+			if err := u.open(); err != nil {
+				return err
+			}
+			message = err.Error()
+			continue
+		}
+		if sha256.Sum256(fresh.encryptionKey) != fingerprint {
+			return errors.New("hush file's keys changed while locked. unsaved changes are lost")
+		}
+		u.tree.encryptionKey = fresh.encryptionKey
+		u.tree.macKey = fresh.macKey
+		u.tree.passwordKey = fresh.passwordKey
+		u.tree.keyfile = fresh.keyfile
+		u.tree.signingKey = fresh.signingKey
+		return u.open()
+	}
+}
@@ -0,0 +1,92 @@
+package hush
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTuiRows(t *testing.T) {
+	u := &tui{tree: newTestTree()}
+	u.tree.set(NewPath("bitpay.com/work/username"), NewPlaintext([]byte("bob"), Private))
+	u.refresh()
+	if len(u.leaves) != 3 {
+		t.Fatalf("got %d leaves: %q", len(u.leaves), u.leaves)
+	}
+
+	var got []string
+	rows, _ := u.rows()
+	for _, row := range rows {
+		got = append(got, strings.Repeat("  ", row.depth)+row.text)
+	}
+	want := []string{
+		"bitpay.com/",
+		"  work/",
+		"    password",
+		"    username",
+		"paypal.com/",
+		"  personal/",
+		"    password",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got rows:\n%s", strings.Join(got, "\n"))
+	}
+
+	// searching narrows the leaves and keeps a valid selection
+	u.selected = 2
+	u.query = "paypal"
+	u.filter()
+	p, ok := u.current()
+	if !ok || p != NewPath("paypal.com/personal/password") {
+		t.Errorf("selected %q after searching", p)
+	}
+	u.query = "nothing matches this"
+	u.filter()
+	if _, ok := u.current(); ok {
+		t.Errorf("nothing should be selected")
+	}
+}
+
+func TestTuiDetails(t *testing.T) {
+	u := &tui{tree: newTestTree()}
+	u.refresh()
+	u.selectPath(NewPath("paypal.com/personal/password"))
+
+	details := strings.Join(u.details(), "\n")
+	if strings.Contains(details, "secret") {
+		t.Errorf("value shown before revealing: %s", details)
+	}
+	u.revealed = true
+	if details := strings.Join(u.details(), "\n"); !strings.Contains(details, "value: secret") {
+		t.Errorf("value not revealed: %s", details)
+	}
+
+	// attachments have no text to reveal
+	p := NewPath("files/keystore")
+	if err := u.tree.Attach(p, bytes.NewReader([]byte{0, 1}), "keystore.jks", "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+	u.refresh()
+	u.selectPath(p)
+	if details := strings.Join(u.details(), "\n"); !strings.Contains(details, "binary attachment") {
+		t.Errorf("attachment revealed: %s", details)
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"abc", 5, "abc  "},
+		{"abcdef", 3, "abc"},
+		{"héllo", 2, "hé"},
+		{"│ ok", 4, "│ ok"},
+	}
+	for _, test := range tests {
+		if got := fit(test.s, test.width); got != test.want {
+			t.Errorf("fit(%q, %d) = %q", test.s, test.width, got)
+		}
+	}
+}
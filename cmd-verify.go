@@ -0,0 +1,34 @@
+package hush
+
+import (
+	"fmt"
+	"io"
+)
+
+// CmdVerify reports whether tree is intact and who signed it.  By the
+// time this function runs, the tree's checksum and signature have
+// already been verified.
+//
+// This function implements "hush verify"
+func CmdVerify(w io.Writer, tree *Tree) error {
+	io.WriteString(w, "checksum: ok\n")
+	signer := tree.Signer()
+	if signer == "" {
+		io.WriteString(w, "signature: none\n")
+		return nil
+	}
+	key, _, err := parseSignerKey(signer)
+	if err != nil {
+		return err
+	}
+	signers, err := readSigners()
+	if err != nil {
+		return err
+	}
+	name, ok := signerName(signers, key)
+	if !ok {
+		name = "untrusted"
+	}
+	fmt.Fprintf(w, "signature: ok\nsigner: %s %s\n", name, fingerprint(key))
+	return nil
+}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"github.com/mndrix/hush"
+)
+
+func main() {
+	hush.DockerCredentialMain()
+}
@@ -0,0 +1,125 @@
+package hush
+
+import (
+	"sort"
+	"strings"
+)
+
+// Paths are stored in plaintext, so completing them needs no password.
+// Shells call "hush __complete" with the words typed so far, the last
+// of which is being completed.
+
+// commandNames lists the commands offered for completion.
+var commandNames = []string{
+	"attach", "audit", "aws-credentials", "breach-check", "completion",
+	"copy", "detach", "export", "git-credential", "grep", "help", "import",
+	"init", "k8s-secret", "keyfile", "ls", "materialize", "meta", "pick",
+	"recipient", "recovery", "rm", "serve", "set", "shell", "signer",
+	"ssh-agent", "template", "terraform-external", "timestamps", "tui",
+	"verify",
+}
+
+// children returns the names of the nodes immediately beneath dir, in
+// order.  Interior nodes end with a slash.  Configuration, checksums,
+// signatures and metadata are left out.
+func (t *Tree) children(dir Path) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, branch := range t.branches {
+		p := branch.path
+		if branch.val == nil || !p.IsUserData() {
+			continue // deleted or not the user's
+		}
+		rel := string(p)
+		if dir != "" {
+			if !dir.HasDescendant(p) {
+				continue
+			}
+			rel = strings.TrimPrefix(rel, string(dir)+"/")
+		}
+		if i := strings.Index(rel, "/"); i >= 0 {
+			rel = rel[:i+1]
+		}
+		if !seen[rel] {
+			seen[rel] = true
+			names = append(names, rel)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completePath returns the ways to complete word, a partial path, by
+// one more level.
+func (t *Tree) completePath(word string) []string {
+	dirPart := ""
+	if i := strings.LastIndex(word, "/"); i >= 0 {
+		dirPart = word[:i+1]
+	}
+	dir := NewPath(strings.TrimSuffix(dirPart, "/"))
+	prefix := word[len(dirPart):]
+
+	var candidates []string
+	for _, name := range t.children(dir) {
+		if strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, dirPart+name)
+		}
+	}
+	return candidates
+}
+
+// Complete returns the candidates for the last of words, which are
+// the arguments typed after "hush".
+func Complete(tree *Tree, words []string) []string {
+	// global options don't count
+	for len(words) > 2 && words[0] == "--keyfile" {
+		words = words[2:]
+	}
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	word := words[len(words)-1]
+
+	var candidates []string
+	if len(words) == 1 {
+		for _, name := range commandNames {
+			if strings.HasPrefix(name, word) {
+				candidates = append(candidates, name)
+			}
+		}
+		return candidates
+	}
+	if strings.HasPrefix(word, "-") {
+		return nil
+	}
+
+	// which argument is being completed, ignoring options?
+	position := 0
+	for _, w := range words[1:] {
+		if !strings.HasPrefix(w, "-") {
+			position++
+		}
+	}
+
+	switch words[0] {
+	case "aws-credentials", "copy", "ls", "rm":
+		return tree.completePath(word)
+	case "attach", "detach", "k8s-secret", "meta", "set":
+		if position == 1 {
+			return tree.completePath(word)
+		}
+	case "grep":
+		if position == 2 {
+			return tree.completePath(word)
+		}
+	case "completion":
+		if position == 1 {
+			for _, shell := range []string{"bash", "fish", "zsh"} {
+				if strings.HasPrefix(shell, word) {
+					candidates = append(candidates, shell)
+				}
+			}
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,58 @@
+package hush
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	useTempHushFile(t)
+	tree := newTestTree()
+	tree.set(NewPath("paypal.com/work/password"), NewPlaintext([]byte("hunter2"), Private))
+	if err := tree.SetMasterPassword([]byte("hunter2"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// completion works on a tree nobody unlocked
+	tree = reload(t, tree)
+	if tree.encryptionKey != nil {
+		t.Fatal("tree should be locked")
+	}
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"te"}, []string{"template", "terraform-external"}},
+		{[]string{"--keyfile", "key", "re"}, []string{"recipient", "recovery"}},
+		{[]string{"ls", ""}, []string{"bitpay.com/", "paypal.com/"}},
+		{[]string{"copy", "pay"}, []string{"paypal.com/"}},
+		{[]string{"copy", "paypal.com/"}, []string{"paypal.com/personal/", "paypal.com/work/"}},
+		{[]string{"rm", "paypal.com/work/p"}, []string{"paypal.com/work/password"}},
+		{[]string{"set", "paypal.com/work/password", "b"}, nil},
+		{[]string{"grep", "--show", "secret", "bit"}, []string{"bitpay.com/"}},
+		{[]string{"ls", "hush-"}, nil},
+		{[]string{"completion", "z"}, []string{"zsh"}},
+	}
+	for _, test := range tests {
+		got := Complete(tree, test.words)
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("Complete(%q) = %q, want %q", test.words, got, test.want)
+		}
+	}
+}
+
+func TestCmdCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var out bytes.Buffer
+		if err := CmdCompletion(&out, shell); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), "__complete") {
+			t.Errorf("%s script doesn't call hush __complete", shell)
+		}
+	}
+	if err := CmdCompletion(&bytes.Buffer{}, "tcsh"); err == nil {
+		t.Errorf("unknown shells should fail")
+	}
+}
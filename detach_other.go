@@ -0,0 +1,9 @@
+//go:build !unix
+
+package hush
+
+import "os/exec"
+
+// detach arranges for cmd to outlive hush.  There's nothing special
+// to do on this platform.
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package hush
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach arranges for cmd to outlive hush and its terminal.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,23 @@
+package hush
+
+// commonPasswords lists passwords which appear most often in public
+// breaches.  Attackers try them first.
+var commonPasswords = []string{
+	"123456", "123456789", "12345678", "password", "qwerty", "12345",
+	"1234567", "111111", "1234567890", "123123", "abc123", "1234",
+	"password1", "iloveyou", "000000", "qwerty123", "dragon", "sunshine",
+	"princess", "letmein", "654321", "monkey", "27653", "1qaz2wsx",
+	"123321", "qwertyuiop", "superman", "asdfghjkl", "trustno1",
+	"welcome", "admin", "login", "master", "hello", "freedom", "whatever",
+	"qazwsx", "football", "baseball", "shadow", "michael", "jennifer",
+	"jordan", "hunter", "ranger", "buster", "soccer", "harley", "batman",
+	"andrew", "tigger", "charlie", "robert", "thomas", "hockey", "daniel",
+	"starwars", "112233", "george", "computer", "michelle",
+	"jessica", "pepper", "zxcvbnm", "ashley", "131313", "mustang",
+	"666666", "121212", "biteme", "access", "flower", "cheese", "summer",
+	"winter", "spring", "autumn", "secret", "changeme", "default",
+	"passw0rd", "p@ssw0rd", "root", "toor", "guest", "test", "testing",
+	"nothing", "maggie", "ginger", "matrix", "killer", "internet",
+	"samsung", "cookie", "purple", "orange", "banana", "chocolate",
+	"butterfly", "angel", "love", "lovely", "family", "friends",
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package hush
+
+import "errors"
+
+// mkfifo creates a named pipe.  This platform doesn't have them.
+func mkfifo(path string) error {
+	return errors.New("named pipes aren't supported on this platform")
+}
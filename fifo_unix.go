@@ -0,0 +1,20 @@
+//go:build unix
+
+package hush
+
+import (
+	"os"
+	"syscall"
+)
+
+// mkfifo creates a named pipe which only its owner may use.
+func mkfifo(path string) error {
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil { // despite the umask
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
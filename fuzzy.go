@@ -0,0 +1,127 @@
+package hush
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Fuzzy matching finds paths which contain the characters of a query
+// in order, though not necessarily next to each other.  Matches are
+// scored in the style of fzf: each matched character earns points,
+// with bonuses for characters at the start of a path component, after
+// a word boundary or right after the previous match.  Gaps between
+// matched characters cost points.  Like other patterns, the query
+// ignores case unless it contains uppercase letters.
+
+const (
+	fuzzyMatch       = 16
+	fuzzyComponent   = 10 // first character of a path component
+	fuzzyBoundary    = 8  // first character of a word
+	fuzzyConsecutive = 6  // immediately after the previous match
+	fuzzyGap         = 1  // per skipped character
+)
+
+// fuzzyBonus returns the bonus for matching the character at index i
+// of s.
+func fuzzyBonus(s []rune, i int) int {
+	if i == 0 || s[i-1] == '/' {
+		return fuzzyComponent
+	}
+	prev := s[i-1]
+	switch {
+	case strings.ContainsRune("-_. @:", prev):
+		return fuzzyBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(s[i]):
+		return fuzzyBoundary
+	case !unicode.IsDigit(prev) && unicode.IsDigit(s[i]):
+		return fuzzyBoundary
+	}
+	return 0
+}
+
+// fuzzyScore returns the score of the best fuzzy match of query within
+// the crumbs of p.  Returns false if p doesn't match at all.
+func fuzzyScore(p Path, query string) (int, bool) {
+	q := []rune(query)
+	s := []rune(strings.Join(p.AsCrumbs(), "/"))
+	if len(q) == 0 {
+		return 0, true
+	}
+	if len(q) > len(s) {
+		return 0, false
+	}
+	ignoreCase := isLowercase(query)
+	equal := func(a, b rune) bool {
+		if ignoreCase {
+			return unicode.ToLower(a) == b
+		}
+		return a == b
+	}
+
+	// best[j] is the highest score for matching the query so far with
+	// its last character at s[j]
+	const none = -1 << 30
+	best := make([]int, len(s))
+	next := make([]int, len(s))
+	for i := range q {
+		gapped := none // best score ending before j-1, less gap costs
+		for j := range s {
+			next[j] = none
+			if gapped > none {
+				gapped -= fuzzyGap
+			}
+			if j >= 2 && best[j-2] > none && best[j-2]-fuzzyGap > gapped {
+				gapped = best[j-2] - fuzzyGap
+			}
+			if !equal(s[j], q[i]) {
+				continue
+			}
+			score := fuzzyMatch + fuzzyBonus(s, j)
+			switch {
+			case i == 0:
+				next[j] = score
+			default:
+				if j >= 1 && best[j-1] > none {
+					next[j] = best[j-1] + score + fuzzyConsecutive
+				}
+				if gapped > none && gapped+score > next[j] {
+					next[j] = gapped + score
+				}
+			}
+		}
+		best, next = next, best
+	}
+
+	score := none
+	for _, b := range best {
+		if b > score {
+			score = b
+		}
+	}
+	return score, score > none
+}
+
+// fuzzyRank returns the paths which match query, best match first.
+// Ties go to shorter paths, then alphabetical order.
+func fuzzyRank(paths []Path, query string) []Path {
+	scores := make(map[Path]int)
+	var ranked []Path
+	for _, p := range paths {
+		if score, ok := fuzzyScore(p, query); ok {
+			scores[p] = score
+			ranked = append(ranked, p)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	return ranked
+}
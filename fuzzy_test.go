@@ -0,0 +1,48 @@
+package hush
+
+import "testing"
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		query string
+		path  string
+		match bool
+	}{
+		{"ppw", "paypal.com/personal/password", true},
+		{"ppwx", "paypal.com/personal/password", false},
+		{"wp", "paypal.com/work/password", true},
+		{"pw", "paypal.com/work/password", true},
+		{"PW", "paypal.com/work/password", false},
+		{"", "anything", true},
+	}
+	for _, test := range tests {
+		_, ok := fuzzyScore(NewPath(test.path), test.query)
+		if ok != test.match {
+			t.Errorf("%q in %q: got %v", test.query, test.path, ok)
+		}
+	}
+}
+
+func TestFuzzyRank(t *testing.T) {
+	paths := []Path{
+		NewPath("example.com/api/key"),
+		NewPath("github.com/work/password"),
+		NewPath("gmail.com/personal/password"),
+		NewPath("sendgrid.com/api-key"),
+	}
+	tests := map[string]string{
+		// query -> best match
+		"gh":     "github.com/work/password",
+		"gmp":    "gmail.com/personal/password",
+		"gwp":    "github.com/work/password",
+		"sgkey":  "sendgrid.com/api-key",
+		"apikey": "example.com/api/key",
+		"exak":   "example.com/api/key",
+	}
+	for query, expect := range tests {
+		ranked := fuzzyRank(paths, query)
+		if len(ranked) == 0 || ranked[0].String() != expect {
+			t.Errorf("%q: got %q, expected %q first", query, ranked, expect)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package hush
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// passwordAlphabet omits characters which are easily confused, like 0
+// and O or 1 and l.
+const passwordAlphabet = "abcdefghijkmnopqrstuvwxyz" +
+	"ABCDEFGHJKLMNPQRSTUVWXYZ" +
+	"23456789" +
+	"-_.!@#%+="
+
+// generatePassword returns a random password with length characters.
+func generatePassword(length int) (string, error) {
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	password := make([]byte, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(password), nil
+}
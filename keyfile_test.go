@@ -0,0 +1,111 @@
+package hush
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStretchPassword(t *testing.T) {
+	password := []byte("hunter2")
+	keyfile := []byte("0123456789abcdef0123456789abcdef")
+	salt := []byte("salt salt salt!!")
+
+	plain := stretchPassword(password, nil, salt)
+	if len(plain) != 32 {
+		t.Errorf("want a 32 byte key, got %d bytes", len(plain))
+	}
+	if !bytes.Equal(plain, stretchPassword(password, nil, salt)) {
+		t.Errorf("stretching should be deterministic")
+	}
+	if bytes.Equal(plain, stretchPassword(password, nil, []byte("other salt......"))) {
+		t.Errorf("salt should change the key")
+	}
+
+	withKeyfile := stretchPassword(password, keyfile, salt)
+	if bytes.Equal(plain, withKeyfile) {
+		t.Errorf("key file should change the key")
+	}
+	if !bytes.Equal(withKeyfile, stretchPassword(password, keyfile, salt)) {
+		t.Errorf("stretching with a key file should be deterministic")
+	}
+	if bytes.Equal(withKeyfile, stretchPassword(nil, keyfile, salt)) {
+		t.Errorf("password should change the key")
+	}
+}
+
+// reload saves tree and loads it again, still locked.
+func reload(t *testing.T, tree *Tree) *Tree {
+	if err := tree.Save(); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := LoadTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestKeyfileRoundTrip(t *testing.T) {
+	useTempHushFile(t)
+	password := []byte("hunter2")
+	keyfile := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		name     string
+		password []byte
+		keyfile  []byte
+	}{
+		{"password", password, nil},
+		{"password and key file", password, keyfile},
+		{"key file alone", nil, keyfile},
+		{"password again", password, nil},
+	}
+	tree := newTestTree()
+	for _, test := range tests {
+		if err := tree.SetMasterPassword(test.password, test.keyfile); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		tree = reload(t, tree)
+
+		needPassword, needKeyfile := tree.Factors()
+		if needPassword != (test.password != nil) || needKeyfile != (test.keyfile != nil) {
+			t.Errorf("%s: wrong factors %v %v", test.name, needPassword, needKeyfile)
+		}
+		if test.keyfile != nil {
+			if err := tree.SetPassphrase(test.password); err == nil {
+				t.Errorf("%s: unlocked without the key file", test.name)
+			}
+			tree.SetKeyfile([]byte("the wrong key file"))
+			if err := tree.SetPassphrase(test.password); err == nil {
+				t.Errorf("%s: unlocked with the wrong key file", test.name)
+			}
+			tree.SetKeyfile(test.keyfile)
+		}
+		if err := tree.SetPassphrase(test.password); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if got, _ := tree.plaintext(NewPath("bitpay.com/work/password")); got != "42 bitcoins" {
+			t.Errorf("%s: got %q", test.name, got)
+		}
+	}
+}
+
+func TestKeyfileNeedsMasterPassword(t *testing.T) {
+	tree := newTestTree()
+	if err := tree.SetMasterPassword([]byte("hunter2"), nil); err != nil {
+		t.Fatal(err)
+	}
+	tree.passwordKey = nil // as if a recipient unlocked it
+
+	var out bytes.Buffer
+	for _, args := range [][]string{{"add", "keyfile"}, {"rm"}} {
+		err := CmdKeyfile(&out, tree, args)
+		if err == nil || !strings.Contains(err.Error(), "master password") {
+			t.Errorf("keyfile %s: want master password error, got %v", args[0], err)
+		}
+	}
+	if out.Len() > 0 {
+		t.Errorf("shouldn't prompt: %q", out.String())
+	}
+}
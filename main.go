@@ -1,12 +1,19 @@
 package hush // import "github.com/mndrix/hush"
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"strings"
+	"time"
 )
 
 // Main implements the main() function of the hush command line tool.
 func Main() {
+	parseGlobalOptions()
 	if len(os.Args) < 2 {
 		usage()
 		return
@@ -17,8 +24,71 @@ func Main() {
 	case "help":
 		CmdHelp(os.Stdout)
 		return
+	case "completion":
+		if len(os.Args) != 3 {
+			die("Usage: hush completion bash|zsh|fish")
+		}
+		err := CmdCompletion(os.Stdout, os.Args[2])
+		if err != nil {
+			die("%s", err.Error())
+		}
+		return
+	case "__complete":
+		// paths are plaintext, so don't ask for a password
+		tree, err := LoadTree()
+		if err == nil {
+			for _, candidate := range Complete(tree, os.Args[2:]) {
+				fmt.Println(candidate)
+			}
+		}
+		return
+	case "__clipboard-restore":
+		if len(os.Args) != 4 {
+			die("Usage: hush __clipboard-restore clipboard timeout")
+		}
+		err := ClipboardRestore(os.Stdin, os.Args[2], os.Args[3])
+		if err != nil {
+			die("%s", err.Error())
+		}
+		return
+	case "__ssh-agent":
+		if len(os.Args) != 3 {
+			die("Usage: hush __ssh-agent socket")
+		}
+		err := SshAgentServe(os.Stdin, os.Stdout, os.Args[2])
+		if err != nil {
+			fmt.Println(err.Error()) // our parent reads stdout
+			os.Exit(1)
+		}
+		return
+	case "signer":
+		err := CmdSigner(os.Stdout, os.Args[2:])
+		if err != nil {
+			die("%s", err.Error())
+		}
+		return
 	case "init":
-		err := CmdInit(os.Stderr, os.Stdin)
+		flags := flag.NewFlagSet("init", flag.ExitOnError)
+		keyfile := flags.String("keyfile", KeyfilePath(), "key file needed to unlock")
+		noPassword := flags.Bool("no-password", false, "unlock with key file alone")
+		flags.Parse(os.Args[2:])
+		err := CmdInit(os.Stderr, os.Stdin, *keyfile, !*noPassword)
+		if err != nil {
+			die("%s", err.Error())
+		}
+		return
+	}
+
+	// unknown commands may be plugins
+	if !isCommand(os.Args[1]) && !strings.HasPrefix(os.Args[1], "__") {
+		program, err := FindPlugin(os.Args[1])
+		if err != nil {
+			usage()
+		}
+		err = RunPlugin(program, os.Args[2:])
+		if exit, ok := err.(*exec.ExitError); ok {
+			os.Exit(exit.ExitCode())
+		}
 		if err != nil {
 			die("%s", err.Error())
 		}
@@ -33,35 +103,220 @@ func Main() {
 		fmt.Fprintf(os.Stderr, "Maybe you need to run 'hush init'?\n")
 		os.Exit(1)
 	}
+	if err == nil && len(os.Args) > 2 && os.Args[1] == "recovery" && os.Args[2] == "restore" {
+		// recovery replaces the password, so don't ask for it
+		err = setSigningKey(tree)
+		if err == nil {
+			err = CmdRecoveryRestore(os.Stderr, os.Stdin, tree)
+		}
+		if err != nil {
+			die("error: %s", err.Error())
+		}
+		return
+	}
+	if err == nil && os.Args[1] == "template" {
+		flags := flag.NewFlagSet("template", flag.ExitOnError)
+		check := flags.Bool("check", false, "only verify that referenced paths exist")
+		output := flags.String("o", "", "write output to this file")
+		flags.Parse(os.Args[2:])
+		var template string
+		if flags.NArg() > 0 {
+			template = flags.Arg(0)
+			flags.Parse(flags.Args()[1:]) // options may follow the template
+		}
+		if template == "" || flags.NArg() > 0 {
+			die("Usage: hush template [--check] [-o file] template")
+		}
+		if !*check { // paths are plaintext, so checking needs no password
+			err = setPassphrase(tree)
+			if err == nil {
+				err = setSigningKey(tree)
+			}
+		}
+		if err == nil {
+			err = CmdTemplate(os.Stdout, tree, template, *output, *check)
+		}
+		if err != nil {
+			die("error: %s", err.Error())
+		}
+		return
+	}
 	if err == nil {
 		err = setPassphrase(tree)
 	}
+	if err == nil {
+		err = setSigningKey(tree)
+	}
 	if err != nil {
 		die("%s", err.Error())
 	}
 
 	// dispatch to command
 	switch os.Args[1] {
+	case "attach":
+		if len(os.Args) != 4 {
+			die("Usage: hush attach path file")
+		}
+		err = CmdAttach(os.Stdout, os.Stdin, tree, NewPath(os.Args[2]), os.Args[3])
+	case "audit":
+		flags := flag.NewFlagSet("audit", flag.ExitOnError)
+		asJSON := flags.Bool("json", false, "write report as JSON")
+		maxAge := flags.Int("max-age", 90, "maximum age of values in days")
+		threshold := flags.Int("threshold", -1, "fail if there are more findings")
+		strength := flags.String("strength", defaultStrengthPattern, "check strength and reuse of leaves matching this pattern")
+		flags.Parse(os.Args[2:])
+		err = CmdAudit(os.Stdout, tree, *asJSON, *maxAge, *threshold, *strength)
+	case "aws-credentials":
+		if len(os.Args) != 3 {
+			die("Usage: hush aws-credentials path")
+		}
+		err = CmdAwsCredentials(os.Stdout, tree, NewPath(os.Args[2]))
+	case "breach-check":
+		flags := flag.NewFlagSet("breach-check", flag.ExitOnError)
+		corpus := flags.String("corpus", "", "sorted file of breached password hashes")
+		ntlm := flags.Bool("ntlm", false, "corpus holds NTLM hashes")
+		flags.Parse(os.Args[2:])
+		err = CmdBreachCheck(os.Stdout, tree, *corpus, *ntlm)
+	case "copy":
+		flags := flag.NewFlagSet("copy", flag.ExitOnError)
+		timeout := flags.String("timeout", "", "how long to keep value on the clipboard")
+		flags.Parse(os.Args[2:])
+		if flags.NArg() != 1 {
+			die("Usage: hush copy [--timeout duration] path")
+		}
+		var d time.Duration
+		d, err = copyTimeout(*timeout)
+		if err == nil {
+			err = CmdCopy(os.Stderr, tree, NewPath(flags.Arg(0)), d)
+		}
+	case "detach":
+		if len(os.Args) != 4 {
+			die("Usage: hush detach path file")
+		}
+		err = CmdDetach(os.Stdout, tree, NewPath(os.Args[2]), os.Args[3])
 	case "export": // hush export
 		err = CmdExport(os.Stdout, tree)
+	case "git-credential":
+		if len(os.Args) != 3 {
+			die("Usage: hush git-credential get|store|erase")
+		}
+		err = CmdGitCredential(os.Stdout, os.Stdin, tree, os.Args[2])
+	case "grep":
+		flags := flag.NewFlagSet("grep", flag.ExitOnError)
+		show := flags.Bool("show", false, "show matching values")
+		fixed := flags.Bool("fixed", false, "match whole values in constant time")
+		flags.Parse(os.Args[2:])
+		if flags.NArg() < 1 || flags.NArg() > 2 {
+			die("Usage: hush grep [--show] [--fixed] pattern [path-pattern]")
+		}
+		err = CmdGrep(os.Stdout, tree, flags.Arg(0), flags.Arg(1), *show, *fixed)
 	case "import":
+		flags := flag.NewFlagSet("import", flag.ExitOnError)
+		format := flags.String("format", "tsv", "input format: tsv or k8s-secret")
+		prefix := flags.String("path", "", "where to put a Secret's keys")
+		flags.Parse(os.Args[2:])
 		var warnings []string
-		warnings, err = CmdImport(os.Stdin, tree)
+		switch *format {
+		case "tsv":
+			warnings, err = CmdImport(os.Stdin, tree)
+		case "k8s-secret":
+			warnings, err = CmdImportK8sSecret(os.Stdin, tree, *prefix)
+		default:
+			die("Usage: hush import [--format=tsv|k8s-secret [--path path]]")
+		}
 		for _, warning := range warnings {
 			warn(warning)
 		}
+	case "k8s-secret":
+		flags := flag.NewFlagSet("k8s-secret", flag.ExitOnError)
+		name := flags.String("name", "", "name of the Secret")
+		namespace := flags.String("namespace", "", "namespace of the Secret")
+		stringData := flags.Bool("string-data", false, "write values as plain strings")
+		flags.Parse(os.Args[2:])
+		var pattern string
+		if flags.NArg() > 0 {
+			pattern = flags.Arg(0)
+			flags.Parse(flags.Args()[1:]) // options may follow the pattern
+		}
+		if pattern == "" || *name == "" || flags.NArg() > 0 {
+			die("Usage: hush k8s-secret pattern --name name [--namespace namespace] [--string-data]")
+		}
+		err = CmdK8sSecret(os.Stdout, tree, pattern, *name, *namespace, *stringData)
+	case "keyfile":
+		err = CmdKeyfile(os.Stderr, tree, os.Args[2:])
 	case "ls":
+		flags := flag.NewFlagSet("ls", flag.ExitOnError)
+		long := flags.Bool("long", false, "include metadata")
+		flags.BoolVar(long, "l", false, "include metadata")
+		flags.Parse(os.Args[2:])
+		if flags.NArg() < 1 {
+			if *long {
+				err = tree.PrintLong(os.Stdout)
+			} else {
+				err = tree.Print(os.Stdout)
+			}
+			break
+		}
+		err = CmdLs(os.Stdout, tree, flags.Arg(0), *long)
+	case "materialize":
+		flags := flag.NewFlagSet("materialize", flag.ExitOnError)
+		fifo := flags.String("fifo", "", "named pipe to create")
+		flags.Parse(os.Args[2:])
+		var template string
+		if flags.NArg() > 0 {
+			template = flags.Arg(0)
+			flags.Parse(flags.Args()[1:]) // options may follow the template
+		}
+		if template == "" || *fifo == "" || flags.NArg() > 0 {
+			die("Usage: hush materialize template --fifo path")
+		}
+		err = CmdMaterialize(os.Stderr, tree, template, *fifo)
+	case "meta":
 		if len(os.Args) < 3 {
-			tree.Print(os.Stdout)
-			return
+			die("Usage: hush meta path [field value]")
 		}
-		err = CmdLs(os.Stdout, tree, os.Args[2])
+		err = CmdMeta(os.Stdout, tree, NewPath(os.Args[2]), os.Args[3:])
+	case "pick":
+		flags := flag.NewFlagSet("pick", flag.ExitOnError)
+		toClipboard := flags.Bool("copy", false, "copy value to the clipboard")
+		timeout := flags.String("timeout", "", "how long to keep value on the clipboard")
+		flags.Parse(os.Args[2:])
+		if flags.NArg() > 1 {
+			die("Usage: hush pick [--copy [--timeout duration]] [query]")
+		}
+		var d time.Duration
+		d, err = copyTimeout(*timeout)
+		if err == nil {
+			err = CmdPick(os.Stdout, tree, flags.Arg(0), *toClipboard, d)
+		}
+	case "recovery":
+		if len(os.Args) < 3 || os.Args[2] != "split" {
+			die("Usage: hush recovery split|restore")
+		}
+		flags := flag.NewFlagSet("recovery split", flag.ExitOnError)
+		n := flags.Int("n", 5, "number of shares to create")
+		k := flags.Int("k", 3, "number of shares needed to restore")
+		flags.Parse(os.Args[3:])
+		err = CmdRecoverySplit(os.Stdout, tree, *n, *k)
+	case "recipient":
+		err = CmdRecipient(os.Stdout, tree, os.Args[2:])
 	case "rm":
 		paths := make([]Path, len(os.Args)-2)
 		for i := 2; i < len(os.Args); i++ {
 			paths[i-2] = NewPath(os.Args[i])
 		}
 		err = CmdRm(tree, paths)
+	case "serve":
+		flags := flag.NewFlagSet("serve", flag.ExitOnError)
+		listen := flags.String("listen", "127.0.0.1:8200", "address or unix:socket to listen on")
+		readOnly := flags.Bool("read-only", false, "refuse changes to the hush file")
+		flags.Parse(os.Args[2:])
+		if flags.NArg() > 0 {
+			die("Usage: hush serve [--listen address|unix:path] [--read-only]")
+		}
+		err = CmdServe(os.Stderr, tree, *listen, *readOnly)
+	case "shell":
+		err = CmdShell(tree)
 	case "set":
 		if len(os.Args) < 4 {
 			die("Usage: hush set path value")
@@ -73,6 +328,30 @@ func Main() {
 			die("%s", err.Error())
 		}
 		err = CmdSet(os.Stdout, tree, p, v)
+	case "ssh-agent":
+		flags := flag.NewFlagSet("ssh-agent", flag.ExitOnError)
+		prefix := flags.String("prefix", "ssh", "subtree holding SSH keys")
+		socket := flags.String("socket", "", "Unix socket to listen on")
+		foreground := flags.Bool("foreground", false, "don't detach from the terminal")
+		flags.Parse(os.Args[2:])
+		if flags.NArg() > 0 {
+			die("Usage: hush ssh-agent [--prefix path] [--socket path] [--foreground]")
+		}
+		err = CmdSshAgent(os.Stdout, tree, *prefix, *socket, *foreground)
+	case "terraform-external":
+		err = CmdTerraformExternal(os.Stdout, os.Stdin, tree)
+	case "timestamps":
+		if len(os.Args) != 3 {
+			die("Usage: hush timestamps public|private")
+		}
+		err = CmdTimestamps(tree, os.Args[2])
+	case "tui":
+		flags := flag.NewFlagSet("tui", flag.ExitOnError)
+		lockAfter := flags.Duration("lock-after", 5*time.Minute, "lock after this long without activity")
+		flags.Parse(os.Args[2:])
+		err = CmdTui(tree, *lockAfter)
+	case "verify":
+		err = CmdVerify(os.Stdout, tree)
 	default:
 		usage()
 	}
@@ -81,19 +360,100 @@ func Main() {
 	}
 }
 
+// parseGlobalOptions removes from os.Args any options which precede
+// the command name.
+func parseGlobalOptions() {
+	for len(os.Args) > 1 {
+		arg := os.Args[1]
+		switch {
+		case arg == "--keyfile" && len(os.Args) > 2:
+			keyfilePath = os.Args[2]
+			os.Args = append(os.Args[:1], os.Args[3:]...)
+		case strings.HasPrefix(arg, "--keyfile="):
+			keyfilePath = strings.TrimPrefix(arg, "--keyfile=")
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		default:
+			return
+		}
+	}
+}
+
+// copyTimeout returns how long to keep values on the clipboard.
+// option is the --timeout option, which overrides
+// HUSH_CLIPBOARD_TIMEOUT.
+func copyTimeout(option string) (time.Duration, error) {
+	if option != "" {
+		return parseClipboardTimeout(option)
+	}
+	return clipboardTimeout()
+}
+
 func usage() {
 	die("Usage: hush [command [arguments]]")
 }
 
-func setPassphrase(t *Tree) error {
-	password, err := AskPassword(os.Stderr, "Password")
+// unlockTree loads the hush file and unlocks it with the user's
+// credentials.
+func unlockTree() (*Tree, error) {
+	tree, err := LoadTree()
+	if err == nil {
+		err = setPassphrase(tree)
+	}
+	if err == nil {
+		err = setSigningKey(tree)
+	}
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return tree, nil
+}
+
+func setPassphrase(t *Tree) error {
+	if filename := os.Getenv("HUSH_IDENTITY"); filename != "" {
+		identity, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		return t.SetIdentity(identity)
+	}
+
+	needPassword, needKeyfile := t.Factors()
+	if needKeyfile {
+		filename := KeyfilePath()
+		if filename == "" {
+			return errors.New("hush file needs a key file. use --keyfile or HUSH_KEYFILE")
+		}
+		keyfile, err := readKeyfile(filename, false)
+		if err != nil {
+			return err
+		}
+		t.SetKeyfile(keyfile)
+	}
+
+	var password []byte
+	if needPassword {
+		var err error
+		password, err = AskPassword(os.Stderr, "Password")
+		if err != nil {
+			return err
+		}
 	}
 
 	return t.SetPassphrase(password)
 }
 
+func setSigningKey(t *Tree) error {
+	filename := os.Getenv("HUSH_SIGNING_KEY")
+	if filename == "" {
+		return nil
+	}
+	key, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return t.SetSigningKey(key)
+}
+
 func die(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, format+"\n", args...)
 	os.Exit(1)
@@ -0,0 +1,260 @@
+package hush
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Each leaf may carry metadata: when it was created and last
+// modified, who modified it, when it expires, tags and free-form
+// notes.  Attachments also record their filename, MIME type and
+// size.  Metadata is stored beneath hush-metadata/<path>/<field> so
+// that it's covered by the tree's checksum and travels with the tree
+// through sorting, encryption and encoding.  Older versions of hush
+// see metadata as ordinary leaves.
+//
+// Author, tags and notes are always encrypted.  Timestamps are
+// encrypted too unless the user asks for them to be public.
+
+// metadataFields lists all metadata fields in display order.
+var metadataFields = []string{
+	"created",
+	"modified",
+	"modified-by",
+	"expires",
+	"tags",
+	"notes",
+	"filename",
+	"mime-type",
+	"size",
+}
+
+const publicTimestampsPath = "hush-configuration/public-timestamps"
+
+// metadataRoot returns the path beneath which p's metadata is stored.
+func metadataRoot(p Path) Path {
+	return NewPath("hush-metadata/" + string(p))
+}
+
+// metadataPath returns the path which stores one of p's metadata
+// fields.
+func metadataPath(p Path, field string) Path {
+	return NewPath("hush-metadata/" + string(p) + "/" + field)
+}
+
+// metadataOwner returns the leaf described by metadata path m and the
+// name of the field.
+func metadataOwner(m Path) (Path, string) {
+	s := strings.TrimPrefix(string(m), "hush-metadata/")
+	i := strings.LastIndex(s, "/")
+	return NewPath(s[:i]), s[i+1:]
+}
+
+func isMetadataField(field string) bool {
+	for _, f := range metadataFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func isTimestampField(field string) bool {
+	return field == "created" || field == "modified" || field == "expires"
+}
+
+// isPublic returns true if p's value is stored without encryption.
+func (t *Tree) isPublic(p Path) bool {
+	if p.IsPublic() {
+		return true
+	}
+	if p.IsMetadata() {
+		_, field := metadataOwner(p)
+		return isTimestampField(field) && t.PublicTimestamps()
+	}
+	return false
+}
+
+// PublicTimestamps returns true if metadata timestamps are stored
+// without encryption.
+func (t *Tree) PublicTimestamps() bool {
+	v, ok := t.get(NewPath(publicTimestampsPath))
+	if !ok {
+		return false
+	}
+	v, err := v.Decode()
+	return err == nil && string(v.plaintext) == "true"
+}
+
+// SetPublicTimestamps changes whether metadata timestamps are stored
+// without encryption.  The tree must be unlocked.
+func (t *Tree) SetPublicTimestamps(public bool) error {
+	if public == t.PublicTimestamps() {
+		return nil
+	}
+
+	// convert existing timestamps to their new privacy
+	privacy := Private
+	if public {
+		privacy = Public
+	}
+	for _, branch := range t.branches {
+		if !branch.path.IsMetadata() {
+			continue
+		}
+		if _, field := metadataOwner(branch.path); !isTimestampField(field) {
+			continue
+		}
+		v, err := t.metadataValue(branch.path)
+		if err != nil {
+			return err
+		}
+		t.set(branch.path, NewPlaintext([]byte(v), privacy))
+	}
+
+	p := NewPath(publicTimestampsPath)
+	if public {
+		t.set(p, NewPlaintext([]byte("true"), Public))
+	} else {
+		t.Delete(p)
+	}
+	return nil
+}
+
+// fixMetadataPrivacy marks public timestamps as public.  That's not
+// possible while loading a tree because the configuration which
+// determines their privacy may not have been loaded yet.
+func (t *Tree) fixMetadataPrivacy() {
+	if !t.PublicTimestamps() {
+		return
+	}
+	for i, branch := range t.branches {
+		v := branch.val
+		if branch.path.IsMetadata() && t.isPublic(branch.path) && v.encoded != "" {
+			t.branches[i].val = NewEncoded(v.encoded, Public)
+		}
+	}
+}
+
+// metadataValue returns the plaintext value stored at metadata path m.
+func (t *Tree) metadataValue(m Path) (string, error) {
+	v, ok := t.get(m)
+	if !ok {
+		return "", nil
+	}
+	var err error
+	if v.privacy == Public {
+		v, err = v.Decode()
+	} else {
+		v, err = v.Plaintext(t.encryptionKey)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, m.String())
+	}
+	return string(v.plaintext), nil
+}
+
+// Metadata returns the metadata fields of leaf p that have values.
+func (t *Tree) Metadata(p Path) (map[string]string, error) {
+	meta := make(map[string]string)
+	for _, field := range metadataFields {
+		v, err := t.metadataValue(metadataPath(p, field))
+		if err != nil {
+			return nil, err
+		}
+		if v != "" {
+			meta[field] = v
+		}
+	}
+	return meta, nil
+}
+
+// SetMetadata sets one of leaf p's metadata fields.  An empty value
+// removes the field.
+func (t *Tree) SetMetadata(p Path, field, value string) error {
+	if !isMetadataField(field) {
+		return fmt.Errorf("unknown metadata field %q. try one of: %s", field, strings.Join(metadataFields, ", "))
+	}
+	if _, ok := t.get(p); !ok {
+		return fmt.Errorf("no such leaf: %s", p)
+	}
+
+	m := metadataPath(p, field)
+	if value == "" {
+		t.Delete(m)
+		return nil
+	}
+	if isTimestampField(field) {
+		when, err := parseTimestamp(value)
+		if err != nil {
+			return err
+		}
+		value = when.Format(time.RFC3339)
+	}
+	privacy := Private
+	if t.isPublic(m) {
+		privacy = Public
+	}
+	t.set(m, NewPlaintext([]byte(value), privacy))
+	return nil
+}
+
+// Timestamp returns the time stored in one of leaf p's timestamp
+// fields.  Returns false if the field is missing or malformed.
+func (t *Tree) Timestamp(p Path, field string) (time.Time, bool) {
+	v, err := t.metadataValue(metadataPath(p, field))
+	if err != nil || v == "" {
+		return time.Time{}, false
+	}
+	when, err := parseTimestamp(v)
+	return when, err == nil
+}
+
+// Touch records that leaf p has just been modified.
+func (t *Tree) Touch(p Path) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, ok := t.get(metadataPath(p, "created")); !ok {
+		t.SetMetadata(p, "created", now)
+	}
+	t.SetMetadata(p, "modified", now)
+	t.SetMetadata(p, "modified-by", author())
+}
+
+// metadataSlice returns leaf p's metadata for display.  Returns nil
+// if p has no metadata.
+func (t *Tree) metadataSlice(p Path) yaml.MapSlice {
+	var slice yaml.MapSlice
+	for _, field := range metadataFields {
+		v, err := t.metadataValue(metadataPath(p, field))
+		if err == nil && v != "" {
+			slice = append(slice, yaml.MapItem{Key: field, Value: v})
+		}
+	}
+	return slice
+}
+
+// author returns the name recorded as the modifier of leaves.
+func author() string {
+	if name := os.Getenv("HUSH_AUTHOR"); name != "" {
+		return name
+	}
+	return os.Getenv("USER")
+}
+
+// parseTimestamp parses either a date or an RFC 3339 timestamp.
+func parseTimestamp(s string) (time.Time, error) {
+	when, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return when, nil
+	}
+	when, err = time.Parse("2006-01-02", s)
+	if err != nil {
+		return when, fmt.Errorf("timestamp should look like 2006-01-02 or RFC 3339: %s", s)
+	}
+	return when, nil
+}
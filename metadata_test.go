@@ -0,0 +1,101 @@
+package hush
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetadataTouch(t *testing.T) {
+	setenv(t, "HUSH_AUTHOR", "alice")
+	tree := newTestTree()
+	p := NewPath("bitpay.com/work/password")
+	tree.SetMetadata(p, "created", "2020-01-02")
+
+	before := time.Now().Add(-time.Second)
+	tree.Touch(p)
+	meta, err := tree.Metadata(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta["created"] != "2020-01-02T00:00:00Z" {
+		t.Errorf("Touch changed created to %s", meta["created"])
+	}
+	if when, ok := tree.Timestamp(p, "modified"); !ok || when.Before(before) {
+		t.Errorf("wrong modified time: %s", meta["modified"])
+	}
+	if meta["modified-by"] != "alice" {
+		t.Errorf("want modified-by alice, got %q", meta["modified-by"])
+	}
+
+	// a new leaf gets a created time too
+	q := NewPath("example.com/password")
+	tree.set(q, NewPlaintext([]byte("new"), Private))
+	tree.Touch(q)
+	if _, ok := tree.Timestamp(q, "created"); !ok {
+		t.Errorf("Touch should set created on a new leaf")
+	}
+}
+
+func TestMetadataLs(t *testing.T) {
+	tree := newTestTree()
+	p := NewPath("bitpay.com/work/password")
+	tree.SetMetadata(p, "notes", "cold wallet")
+
+	var short, long bytes.Buffer
+	if err := CmdLs(&short, tree, "", false); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(short.String(), "cold wallet") {
+		t.Errorf("ls shows metadata:\n%s", short.String())
+	}
+	if err := CmdLs(&long, tree, "", true); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"value: 42 bitcoins", "notes: cold wallet", "personal:\n    password: secret"} {
+		if !strings.Contains(long.String(), want) {
+			t.Errorf("ls --long missing %q:\n%s", want, long.String())
+		}
+	}
+}
+
+func TestMetadataPublicTimestamps(t *testing.T) {
+	useTempHushFile(t)
+	tree := newTestTree()
+	p := NewPath("bitpay.com/work/password")
+	tree.SetMetadata(p, "modified", "2020-01-02")
+	tree.SetMetadata(p, "notes", "cold wallet")
+
+	for _, public := range []bool{true, false} {
+		privacy := "private"
+		if public {
+			privacy = "public"
+		}
+		if err := CmdTimestamps(tree, privacy); err != nil {
+			t.Fatal(err)
+		}
+		tree, err := LoadTree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tree.PublicTimestamps() != public {
+			t.Errorf("timestamps should be %s", privacy)
+		}
+
+		// public timestamps are readable without a password
+		v, _ := tree.get(metadataPath(p, "modified"))
+		if (v.privacy == Public) != public {
+			t.Errorf("timestamp should be %s in the file", privacy)
+		}
+		if public {
+			when, ok := tree.Timestamp(p, "modified")
+			if !ok || !when.Equal(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("wrong public timestamp: %s", when)
+			}
+		}
+		if v, _ := tree.get(metadataPath(p, "notes")); v.privacy == Public {
+			t.Errorf("notes should always be private")
+		}
+	}
+}
@@ -49,8 +49,23 @@ func (p Path) IsConfiguration() bool {
 // IsPublic returns true if p is a path whose value must be publicly
 // visible.
 func (p Path) IsPublic() bool {
+	if p.IsRecipient() {
+		switch p.AsCrumbs()[len(p.AsCrumbs())-1] {
+		case "public-key", "ephemeral-key", "salt":
+			return true
+		}
+	}
 	return p == "hush-configuration/salt" ||
-		p == "hush-tree-checksum"
+		p == "hush-configuration/factors" ||
+		p == "hush-configuration/public-timestamps" ||
+		p.IsChecksum() ||
+		p.IsSignature()
+}
+
+// IsRecipient returns true if p is a path within the configuration
+// of a recipient.
+func (p Path) IsRecipient() bool {
+	return strings.HasPrefix(string(p), "hush-configuration/recipients/")
 }
 
 // IsEncryptionKey returns true if p is the path that stores the
@@ -70,3 +85,31 @@ func (p Path) IsMacKey() bool {
 func (p Path) IsChecksum() bool {
 	return p == "hush-tree-checksum"
 }
+
+// IsSignature returns true if p is one of the paths that store the
+// tree's signature and its signer's public key.
+func (p Path) IsSignature() bool {
+	return p == "hush-tree-signature" || p == "hush-tree-signer"
+}
+
+// IsMetadata returns true if p is a path which stores metadata about
+// another leaf.
+func (p Path) IsMetadata() bool {
+	return strings.HasPrefix(string(p), "hush-metadata/")
+}
+
+// IsAttachment returns true if p is a path which stores part of the
+// content attached to another leaf.
+func (p Path) IsAttachment() bool {
+	return strings.HasPrefix(string(p), "hush-attachments/")
+}
+
+// IsUserData returns true if p is a path which stores the user's own
+// data, rather than hush's bookkeeping.
+func (p Path) IsUserData() bool {
+	return !p.IsConfiguration() &&
+		!p.IsChecksum() &&
+		!p.IsSignature() &&
+		!p.IsMetadata() &&
+		!p.IsAttachment()
+}
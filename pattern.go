@@ -0,0 +1,181 @@
+package hush
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Patterns select paths within a tree.  A prefix chooses the syntax:
+//
+//	re:       a regular expression for each path component
+//	path-re:  a regular expression for the whole path
+//	glob:     a shell-style glob, where ** spans any number of levels
+//
+// Without a prefix, each component is matched as a substring.  Every
+// syntax uses smartcase: patterns without uppercase letters ignore
+// case.
+
+// compilePattern returns a function which reports whether a path
+// matches pattern.
+func compilePattern(pattern string) (func(Path) bool, error) {
+	switch {
+	case strings.HasPrefix(pattern, "re:"):
+		return componentRegexp(strings.TrimPrefix(pattern, "re:"))
+	case strings.HasPrefix(pattern, "path-re:"):
+		expr := strings.TrimPrefix(pattern, "path-re:")
+		re, err := compileSmartcase(expr)
+		if err != nil {
+			return nil, err
+		}
+		return func(p Path) bool { return re.MatchString(p.String()) }, nil
+	case strings.HasPrefix(pattern, "glob:"):
+		expr := globToRegexp(strings.TrimPrefix(pattern, "glob:"))
+		re, err := compileSmartcase(expr)
+		if err != nil {
+			return nil, err
+		}
+		return func(p Path) bool { return re.MatchString(p.String()) }, nil
+	}
+	return func(p Path) bool { return matches(p, pattern) }, nil
+}
+
+func isLowercase(s string) bool {
+	return s == strings.ToLower(s)
+}
+
+// isLowercaseRegexp is like isLowercase for a regular expression.
+// Uppercase letters in escapes like \S and \W, in Unicode classes like
+// \p{Lu}, in flags and in group names don't count.
+func isLowercaseRegexp(expr string) bool {
+	rs := []rune(expr)
+	for i := 0; i < len(rs); i++ {
+		switch {
+		case rs[i] == '\\' && i+1 < len(rs):
+			i++
+			switch rs[i] {
+			case 'p', 'P':
+				if i+1 < len(rs) && rs[i+1] == '{' {
+					for i < len(rs) && rs[i] != '}' {
+						i++
+					}
+				} else {
+					i++ // one letter class name
+				}
+			case 'Q':
+				// literal text up to \E
+				for i++; i < len(rs) && !(rs[i] == '\\' && i+1 < len(rs) && rs[i+1] == 'E'); i++ {
+					if unicode.IsUpper(rs[i]) {
+						return false
+					}
+				}
+				i++
+			}
+		case rs[i] == '(' && i+1 < len(rs) && rs[i+1] == '?':
+			for i < len(rs) && rs[i] != ':' && rs[i] != ')' && rs[i] != '>' {
+				i++
+			}
+		case unicode.IsUpper(rs[i]):
+			return false
+		}
+	}
+	return true
+}
+
+// compileSmartcase compiles a regular expression which ignores case
+// unless it contains uppercase letters.
+func compileSmartcase(expr string) (*regexp.Regexp, error) {
+	if isLowercaseRegexp(expr) {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	return re, errors.Wrap(err, "pattern")
+}
+
+// matches returns true if each subpattern is a substring of the
+// corresponding component of p.
+func matches(p Path, pattern string) bool {
+	ps := p.AsCrumbs()
+	patterns := strings.Split(pattern, "/")
+	if len(patterns) > len(ps) {
+		return false
+	}
+
+	ignoreCase := isLowercase(pattern)
+	for i, pattern := range patterns {
+		haystack := ps[i]
+		if ignoreCase {
+			haystack = strings.ToLower(haystack)
+		}
+		if !strings.Contains(haystack, pattern) {
+			return false
+		}
+	}
+	return true
+}
+
+// componentRegexp is like matches but each subpattern is a regular
+// expression.
+func componentRegexp(pattern string) (func(Path) bool, error) {
+	var res []*regexp.Regexp
+	for _, expr := range strings.Split(pattern, "/") {
+		re, err := compileSmartcase(expr)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+
+	return func(p Path) bool {
+		ps := p.AsCrumbs()
+		if len(res) > len(ps) {
+			return false
+		}
+		for i, re := range res {
+			if !re.MatchString(ps[i]) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// globToRegexp converts a glob into an equivalent regular expression.
+// A glob matches a path, or any of its ancestors, in its entirety.
+func globToRegexp(glob string) string {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("(/.*)?$")
+	return re.String()
+}
@@ -0,0 +1,105 @@
+package hush
+
+import "testing"
+
+func TestPatterns(t *testing.T) {
+	paths := []string{
+		"paypal.com/personal/password",
+		"paypal.com/work/password",
+		"prod/aws/access-key",
+		"prod/aws/secret-key",
+		"staging/eu/aws/secret-key",
+		"production/db/password",
+	}
+	tests := map[string][]string{
+		"pay/work": {"paypal.com/work/password"},
+		"PAY":      {},
+		"re:^prod$": {
+			"prod/aws/access-key",
+			"prod/aws/secret-key",
+		},
+		"re:^(prod|staging)$/aws": {
+			"prod/aws/access-key",
+			"prod/aws/secret-key",
+		},
+		"path-re:secret-key$": {
+			"prod/aws/secret-key",
+			"staging/eu/aws/secret-key",
+		},
+		"glob:**/aws/*-key": {
+			"prod/aws/access-key",
+			"prod/aws/secret-key",
+			"staging/eu/aws/secret-key",
+		},
+		"glob:pay*/[pw]???": {
+			"paypal.com/work/password",
+		},
+		"glob:pay*/[!p]*": {
+			"paypal.com/work/password",
+		},
+		"glob:prod": {
+			"prod/aws/access-key",
+			"prod/aws/secret-key",
+		},
+		"glob:**/password": {
+			"paypal.com/personal/password",
+			"paypal.com/work/password",
+			"production/db/password",
+		},
+	}
+	for pattern, expect := range tests {
+		match, err := compilePattern(pattern)
+		if err != nil {
+			t.Errorf("%s: %s", pattern, err)
+			continue
+		}
+		var got []string
+		for _, p := range paths {
+			if match(NewPath(p)) {
+				got = append(got, p)
+			}
+		}
+		if len(got) != len(expect) {
+			t.Errorf("%s: got %q, expected %q", pattern, got, expect)
+			continue
+		}
+		for i := range got {
+			if got[i] != expect[i] {
+				t.Errorf("%s: got %q, expected %q", pattern, got, expect)
+				break
+			}
+		}
+	}
+
+	if _, err := compilePattern("re:("); err == nil {
+		t.Errorf("invalid regexp should fail")
+	}
+}
+
+func TestIsLowercaseRegexp(t *testing.T) {
+	tests := map[string]bool{
+		`prod\S+`:      true,
+		`\W\D\B\A\z`:   true,
+		`\p{Lu}\PL`:    true,
+		`(?P<Env>x)`:   true,
+		`(?U)a+`:       true,
+		`\Qa.b\E`:      true,
+		`Prod`:         false,
+		`\Sprod\W+AWS`: false,
+		`\QA.b\E`:      false,
+		`[A-Z]`:        false,
+	}
+	for expr, expect := range tests {
+		if got := isLowercaseRegexp(expr); got != expect {
+			t.Errorf("%s: got %v, expected %v", expr, got, expect)
+		}
+	}
+
+	re, err := compileSmartcase(`^secret\S*$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !re.MatchString("SECRET-KEY") {
+		t.Errorf("escapes shouldn't make a pattern case sensitive")
+	}
+}
@@ -0,0 +1,219 @@
+package hush
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Commands which hush doesn't know are plugins: "hush foo" runs
+// "hush-foo" from PATH, like git does.  The plugin gets HUSH_FILE
+// naming the hush file and HUSH_SESSION naming two inherited file
+// descriptors, "3,4".  The plugin writes requests to the second and
+// reads responses from the first.  Each request and response is a JSON
+// object on one line:
+//
+//	{"op":"ls","pattern":"paypal"}          {"paths":["paypal.com/password"]}
+//	{"op":"get","path":"paypal.com/password"} {"value":"secret"}
+//	{"op":"set","path":"x/y","value":"z"}     {}
+//	{"op":"rm","path":"x/y"}                  {"removed":1}
+//	{"op":"save"}                             {}
+//
+// A failed request gets {"error":"..."}.  hush unlocks the tree the
+// first time a request needs it, so plugins which only list paths
+// never cause a password prompt.  Changes are written only on "save".
+
+// isCommand returns true if hush implements command itself.
+func isCommand(command string) bool {
+	for _, name := range commandNames {
+		if name == command {
+			return true
+		}
+	}
+	return false
+}
+
+// FindPlugin returns the location of the program implementing the
+// plugin command.
+func FindPlugin(command string) (string, error) {
+	return exec.LookPath("hush-" + command)
+}
+
+// pluginRequest is one request from a plugin.
+type pluginRequest struct {
+	Op      string `json:"op"`
+	Path    string `json:"path,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Value   string `json:"value,omitempty"`
+}
+
+// pluginResponse answers a pluginRequest.
+type pluginResponse struct {
+	Error   string   `json:"error,omitempty"`
+	Value   *string  `json:"value,omitempty"`
+	Paths   []string `json:"paths,omitempty"`
+	Removed *int     `json:"removed,omitempty"`
+}
+
+// session serves requests from a plugin.  The tree is loaded and
+// unlocked lazily.
+type session struct {
+	tree     *Tree
+	unlocked bool
+}
+
+// RunPlugin runs program with args, serving its session requests
+// until it exits.
+func RunPlugin(program string, args []string) error {
+	hushPath, err := HushPath()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	hushPath, err = filepath.Abs(hushPath)
+	if err != nil {
+		return err
+	}
+
+	// plugin reads responses from fd 3 and writes requests to fd 4
+	respR, respW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	reqR, reqW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(program, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "HUSH_FILE="+hushPath, "HUSH_SESSION=3,4")
+	cmd.ExtraFiles = []*os.File{respR, reqW}
+	err = cmd.Start()
+	respR.Close()
+	reqW.Close()
+	if err != nil {
+		reqR.Close()
+		respW.Close()
+		return err
+	}
+
+	s := &session{}
+	done := make(chan struct{})
+	go func() {
+		s.serve(reqR, respW)
+		close(done)
+	}()
+	err = cmd.Wait()
+	respW.Close()
+	<-done
+	reqR.Close()
+	return err
+}
+
+// serve answers requests from r on w until r is closed.
+func (s *session) serve(r io.Reader, w io.Writer) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	encoder := json.NewEncoder(w)
+	for scanner.Scan() {
+		var req pluginRequest
+		var resp pluginResponse
+		err := json.Unmarshal(scanner.Bytes(), &req)
+		if err == nil {
+			resp, err = s.handle(req)
+		}
+		if err != nil {
+			resp = pluginResponse{Error: err.Error()}
+		}
+		if encoder.Encode(resp) != nil {
+			return // plugin stopped listening
+		}
+	}
+}
+
+// load reads the tree, unlocking it if needed.
+func (s *session) load(unlock bool) error {
+	if s.tree == nil {
+		tree, err := LoadTree()
+		if err != nil {
+			return err
+		}
+		s.tree = tree
+	}
+	if unlock && !s.unlocked {
+		err := setPassphrase(s.tree)
+		if err == nil {
+			err = setSigningKey(s.tree)
+		}
+		if err != nil {
+			return err
+		}
+		s.unlocked = true
+	}
+	return nil
+}
+
+func (s *session) handle(req pluginRequest) (pluginResponse, error) {
+	var resp pluginResponse
+	switch req.Op {
+	case "ls":
+		if err := s.load(false); err != nil {
+			return resp, err
+		}
+		match, err := compilePattern(req.Pattern)
+		if err != nil {
+			return resp, err
+		}
+		s.tree.Sort()
+		resp.Paths = []string{}
+		for _, branch := range s.tree.branches {
+			if branch.path.IsUserData() && match(branch.path) {
+				resp.Paths = append(resp.Paths, branch.path.String())
+			}
+		}
+	case "get":
+		if err := s.load(true); err != nil {
+			return resp, err
+		}
+		value, err := s.tree.plaintext(NewPath(req.Path))
+		if err != nil {
+			return resp, err
+		}
+		resp.Value = &value
+	case "set":
+		if err := s.load(true); err != nil {
+			return resp, err
+		}
+		p := NewPath(req.Path)
+		if err := checkSettable(p); err != nil {
+			return resp, err
+		}
+		s.tree.setLeaf(p, NewPlaintext([]byte(req.Value), Private))
+		s.tree.Touch(p)
+	case "rm":
+		if err := s.load(true); err != nil {
+			return resp, err
+		}
+		p := NewPath(req.Path)
+		if !p.IsUserData() {
+			return resp, fmt.Errorf("can't remove %s", p)
+		}
+		n := s.tree.Delete(p)
+		resp.Removed = &n
+	case "save":
+		if err := s.load(true); err != nil {
+			return resp, err
+		}
+		if err := s.tree.Save(); err != nil {
+			return resp, err
+		}
+	default:
+		return resp, fmt.Errorf("unknown op %q", req.Op)
+	}
+	return resp, nil
+}
@@ -0,0 +1,42 @@
+package hush
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSessionRemoveListSet(t *testing.T) {
+	useTempHushFile(t)
+	s := &session{tree: newTestTree(), unlocked: true}
+	requests := strings.Join([]string{
+		`{"op":"rm","path":"paypal.com"}`,
+		`{"op":"ls"}`,
+		`{"op":"set","path":"example.com/password","value":"hunter2"}`,
+		`{"op":"save"}`,
+		`{"op":"ls"}`,
+		`{"op":"get","path":"example.com/password"}`,
+	}, "\n")
+	var out bytes.Buffer
+	s.serve(strings.NewReader(requests), &out)
+
+	want := strings.Join([]string{
+		`{"removed":1}`,
+		`{"paths":["bitpay.com/work/password"]}`,
+		`{}`,
+		`{}`,
+		`{"paths":["bitpay.com/work/password","example.com/password"]}`,
+		`{"value":"hunter2"}`,
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Errorf("got responses:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	saved, err := LoadTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := saved.get(NewPath("example.com/password")); !ok {
+		t.Errorf("example.com/password is missing from the hush file")
+	}
+}
@@ -0,0 +1,466 @@
+package hush
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/ssh"
+)
+
+// A recipient is someone, besides the holder of the master password,
+// who can unlock a hush file.  Each recipient has an X25519 public key
+// to which copies of the tree's encryption and MAC keys are wrapped.
+// Recipients who unlock with a password keep their X25519 private key
+// in the hush file, encrypted with a key stretched from that password.
+//
+// A recipient's configuration lives beneath
+// hush-configuration/recipients/<name>/ in these leaves:
+//
+//     public-key      age or SSH public key (public)
+//     ephemeral-key   sender's half of the key agreement (public)
+//     encryption-key  tree's encryption key, wrapped
+//     mac-key         tree's MAC key, wrapped
+//     salt            password salt (public, password recipients only)
+//     private-key     X25519 private key (password recipients only)
+
+const recipientsRoot = "hush-configuration/recipients/"
+
+// recipientPath returns the path of a leaf in a recipient's configuration.
+func recipientPath(name, leaf string) Path {
+	return NewPath(recipientsRoot + name + "/" + leaf)
+}
+
+// Recipients returns the names of everyone who can unlock this tree,
+// except the holder of the master password.
+func (t *Tree) Recipients() []string {
+	var names []string
+	for _, branch := range t.branches {
+		p := branch.path
+		if p.IsRecipient() && strings.HasSuffix(string(p), "/public-key") {
+			names = append(names, p.AsCrumbs()[2])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RecipientKey returns the public key text of the named recipient.
+func (t *Tree) RecipientKey(name string) (string, bool) {
+	v, ok := t.get(recipientPath(name, "public-key"))
+	if !ok {
+		return "", false
+	}
+	v, err := v.Decode()
+	if err != nil {
+		return "", false
+	}
+	return string(v.plaintext), true
+}
+
+// IsPasswordRecipient returns true if the named recipient unlocks the
+// tree with a password rather than a private key.
+func (t *Tree) IsPasswordRecipient(name string) bool {
+	_, ok := t.get(recipientPath(name, "private-key"))
+	return ok
+}
+
+func validRecipientName(name string) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid recipient name: %q", name)
+	}
+	return nil
+}
+
+// AddRecipient grants access to this tree for the holder of the
+// private key matching id.  id is either an age public key
+// ("age1...") or an SSH ed25519 public key.
+func (t *Tree) AddRecipient(name, id string) error {
+	if err := validRecipientName(name); err != nil {
+		return err
+	}
+	if _, ok := t.RecipientKey(name); ok {
+		return fmt.Errorf("recipient %s already exists", name)
+	}
+	public, err := parseRecipient(id)
+	if err != nil {
+		return err
+	}
+	t.set(recipientPath(name, "public-key"), NewPlaintext([]byte(id), Public))
+	return t.wrapFor(name, public)
+}
+
+// AddPasswordRecipient grants access to this tree for anyone who knows
+// password.
+func (t *Tree) AddPasswordRecipient(name string, password []byte) error {
+	if err := validRecipientName(name); err != nil {
+		return err
+	}
+	if _, ok := t.RecipientKey(name); ok {
+		return fmt.Errorf("recipient %s already exists", name)
+	}
+
+	private, err := randomBytes(curve25519.ScalarSize)
+	if err != nil {
+		return err
+	}
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return err
+	}
+	id, err := bech32Encode("age", public)
+	if err != nil {
+		return err
+	}
+	salt, err := randomBytes(16)
+	if err != nil {
+		return err
+	}
+	pwKey := stretchPassword(password, nil, salt)
+
+	t.set(recipientPath(name, "public-key"), NewPlaintext([]byte(id), Public))
+	t.set(recipientPath(name, "salt"), NewPlaintext(salt, Public))
+	v := NewPlaintext(private, Private).Ciphertext(pwKey)
+	t.set(recipientPath(name, "private-key"), v)
+	return t.wrapFor(name, public)
+}
+
+// RemoveRecipient revokes access for the named recipient.  The tree's
+// keys are rotated so that the recipient can't read anything written
+// afterwards.  Rotating keys wraps them for the master password, so the
+// tree must have been unlocked with it, not with a recipient's password
+// or identity.  Otherwise, the tree is left unchanged.
+func (t *Tree) RemoveRecipient(name string) error {
+	if _, ok := t.RecipientKey(name); !ok {
+		return fmt.Errorf("no such recipient: %s", name)
+	}
+	if err := t.requireMasterPassword("removing a recipient"); err != nil {
+		return err
+	}
+	t.Delete(NewPath(recipientsRoot + name))
+	return t.rotateKeys()
+}
+
+// requireMasterPassword returns an error explaining that action needs
+// the master password, unless this tree was unlocked with it.  A tree
+// unlocked by a recipient knows its keys but can't rewrap them for the
+// master password.
+func (t *Tree) requireMasterPassword(action string) error {
+	if _, ok := t.get(NewPath("hush-configuration/encryption-key")); ok && t.passwordKey == nil {
+		return fmt.Errorf("%s requires the master password. unlock without HUSH_IDENTITY or a recipient's password", action)
+	}
+	return nil
+}
+
+// wrapFor stores copies of the tree's keys which only the holder of
+// public's private key can unwrap.
+func (t *Tree) wrapFor(name string, public []byte) error {
+	ephemeral, kek, err := wrappingKey(public)
+	if err != nil {
+		return err
+	}
+	t.set(recipientPath(name, "ephemeral-key"), NewPlaintext(ephemeral, Public))
+	v := NewPlaintext(t.encryptionKey, Private).Ciphertext(kek)
+	t.set(recipientPath(name, "encryption-key"), v)
+	v = NewPlaintext(t.macKey, Private).Ciphertext(kek)
+	t.set(recipientPath(name, "mac-key"), v)
+	return nil
+}
+
+// unwrapFor uses a recipient's private key to recover the tree's keys.
+func (t *Tree) unwrapFor(name string, private []byte) error {
+	v, ok := t.get(recipientPath(name, "ephemeral-key"))
+	if !ok {
+		return fmt.Errorf("recipient %s missing ephemeral key", name)
+	}
+	v, err := v.Decode()
+	if err != nil {
+		return errors.Wrap(err, "decoding ephemeral key")
+	}
+	kek, err := unwrappingKey(private, v.plaintext)
+	if err != nil {
+		return err
+	}
+
+	v, ok = t.get(recipientPath(name, "encryption-key"))
+	if !ok {
+		return fmt.Errorf("recipient %s missing encryption key", name)
+	}
+	encryptionKey, err := v.Plaintext(kek)
+	if err != nil {
+		return err
+	}
+	v, ok = t.get(recipientPath(name, "mac-key"))
+	if !ok {
+		return fmt.Errorf("recipient %s missing MAC key", name)
+	}
+	macKey, err := v.Plaintext(kek)
+	if err != nil {
+		return err
+	}
+
+	t.encryptionKey = encryptionKey.plaintext
+	t.macKey = macKey.plaintext
+	return nil
+}
+
+// unlockRecipientPassword tries to unlock the tree using a password
+// recipient's password.
+func (t *Tree) unlockRecipientPassword(name string, password []byte) error {
+	v, ok := t.get(recipientPath(name, "salt"))
+	if !ok {
+		return fmt.Errorf("recipient %s has no password", name)
+	}
+	v, err := v.Decode()
+	if err != nil {
+		return errors.Wrap(err, "decoding salt")
+	}
+	pwKey := stretchPassword(password, nil, v.plaintext)
+
+	v, ok = t.get(recipientPath(name, "private-key"))
+	if !ok {
+		return fmt.Errorf("recipient %s has no password", name)
+	}
+	v, err = v.Plaintext(pwKey)
+	if err != nil {
+		return err
+	}
+	return t.unwrapFor(name, v.plaintext)
+}
+
+// SetIdentity unlocks the tree with a recipient's private key.  The
+// identity is the content of an age identity file or an OpenSSH
+// ed25519 private key.
+func (t *Tree) SetIdentity(identity []byte) error {
+	private, err := parseIdentity(identity)
+	if err != nil {
+		return err
+	}
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range t.Recipients() {
+		id, _ := t.RecipientKey(name)
+		p, err := parseRecipient(id)
+		if err != nil || !bytes.Equal(p, public) {
+			continue
+		}
+		err = t.unwrapFor(name, private)
+		if err != nil {
+			return errors.Wrap(err, "unwrapping keys for "+name)
+		}
+		return t.verifyChecksum()
+	}
+	return errors.New("identity is not a recipient of this hush file")
+}
+
+// rotateKeys replaces the tree's encryption and MAC keys with fresh
+// ones.  Every leaf is decrypted so that Save() encrypts it again with
+// the new key.  The new keys are then wrapped for the master password
+// and for each remaining recipient.
+func (t *Tree) rotateKeys() error {
+	_, hasMaster := t.get(NewPath("hush-configuration/encryption-key"))
+	if hasMaster && t.passwordKey == nil {
+		return errors.New("rotating keys requires the master password")
+	}
+
+	t.Sort() // remove deleted branches
+	tree := t.Decrypt()
+	var err error
+	tree.encryptionKey, err = randomBytes(32)
+	if err != nil {
+		return err
+	}
+	tree.macKey, err = randomBytes(32)
+	if err != nil {
+		return err
+	}
+
+	if hasMaster {
+		tree.wrapMaster(t.passwordKey)
+	}
+	for _, name := range tree.Recipients() {
+		id, _ := tree.RecipientKey(name)
+		public, err := parseRecipient(id)
+		if err != nil {
+			return errors.Wrap(err, "recipient "+name)
+		}
+		err = tree.wrapFor(name, public)
+		if err != nil {
+			return err
+		}
+	}
+
+	*t = *tree
+	return nil
+}
+
+// wrapMaster stores the tree's keys encrypted with the master
+// password's stretched key.
+func (t *Tree) wrapMaster(pwKey []byte) {
+	p := NewPath("hush-configuration/encryption-key")
+	t.set(p, NewPlaintext(t.encryptionKey, Private).Ciphertext(pwKey))
+	p = NewPath("hush-configuration/mac-key")
+	t.set(p, NewPlaintext(t.macKey, Private).Ciphertext(pwKey))
+	t.passwordKey = pwKey
+}
+
+// wrappingKey generates an ephemeral X25519 key pair and derives a key
+// encryption key shared with the holder of public's private key.
+func wrappingKey(public []byte) (ephemeral, kek []byte, err error) {
+	private, err := randomBytes(curve25519.ScalarSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	ephemeral, err = curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	shared, err := curve25519.X25519(private, public)
+	if err != nil {
+		return nil, nil, err
+	}
+	kek, err = deriveKek(shared, ephemeral, public)
+	return ephemeral, kek, err
+}
+
+// unwrappingKey derives the key encryption key created by
+// wrappingKey, given the recipient's private key.
+func unwrappingKey(private, ephemeral []byte) ([]byte, error) {
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := curve25519.X25519(private, ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	return deriveKek(shared, ephemeral, public)
+}
+
+func deriveKek(shared, ephemeral, public []byte) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeral...), public...)
+	r := hkdf.New(sha256.New, shared, salt, []byte("hush recipient"))
+	kek := make([]byte, 32)
+	_, err := io.ReadFull(r, kek)
+	return kek, err
+}
+
+// parseRecipient converts an age or SSH ed25519 public key into an
+// X25519 public key.
+func parseRecipient(id string) ([]byte, error) {
+	id = strings.TrimSpace(id)
+	if strings.HasPrefix(id, "age1") {
+		hrp, data, err := bech32Decode(id)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing age recipient")
+		}
+		if hrp != "age" || len(data) != curve25519.PointSize {
+			return nil, errors.New("malformed age recipient")
+		}
+		return data, nil
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(id))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing recipient")
+	}
+	ck, ok := key.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, errors.New("unsupported SSH key type: " + key.Type())
+	}
+	pub, ok := ck.CryptoPublicKey().(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("unsupported SSH key type: " + key.Type())
+	}
+	return edwardsToMontgomery(pub)
+}
+
+// parseIdentity converts an age identity or an OpenSSH ed25519
+// private key into an X25519 private key.  If the SSH key is protected
+// by a passphrase, the user is prompted for it.
+func parseIdentity(identity []byte) ([]byte, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(identity))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "AGE-SECRET-KEY-1") {
+			continue
+		}
+		hrp, data, err := bech32Decode(line)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing age identity")
+		}
+		if hrp != "age-secret-key-" || len(data) != curve25519.ScalarSize {
+			return nil, errors.New("malformed age identity")
+		}
+		return data, nil
+	}
+
+	key, err := parseEd25519Key(identity)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing identity")
+	}
+	return edwardsPrivateToMontgomery(key), nil
+}
+
+// curve25519P is the prime 2^255 - 19
+var curve25519P, _ = new(big.Int).SetString(
+	"7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffed",
+	16,
+)
+
+// edwardsToMontgomery converts an ed25519 public key into the
+// equivalent X25519 public key using the birational map u = (1+y)/(1-y).
+func edwardsToMontgomery(pub ed25519.PublicKey) ([]byte, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("malformed ed25519 public key")
+	}
+	y := littleEndianInt(pub)
+	y.SetBit(y, 255, 0) // discard sign of x
+
+	one := big.NewInt(1)
+	num := new(big.Int).Add(one, y)
+	den := new(big.Int).Sub(one, y)
+	den.Mod(den, curve25519P)
+	if den.ModInverse(den, curve25519P) == nil {
+		return nil, errors.New("ed25519 public key has no X25519 equivalent")
+	}
+	u := num.Mul(num, den)
+	u.Mod(u, curve25519P)
+
+	// big.Int is big endian, X25519 is little endian
+	b := u.FillBytes(make([]byte, curve25519.PointSize))
+	reverse(b)
+	return b, nil
+}
+
+// edwardsPrivateToMontgomery converts an ed25519 private key into the
+// equivalent X25519 private key.
+func edwardsPrivateToMontgomery(priv ed25519.PrivateKey) []byte {
+	h := sha512.Sum512(priv.Seed())
+	return h[:curve25519.ScalarSize] // X25519 performs clamping
+}
+
+func littleEndianInt(b []byte) *big.Int {
+	be := append([]byte{}, b...)
+	reverse(be)
+	return new(big.Int).SetBytes(be)
+}
+
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
@@ -0,0 +1,155 @@
+package hush
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestTree returns an unlocked tree with a few leaves.
+func newTestTree() *Tree {
+	t := newT(nil)
+	t.encryptionKey = testEncryptionKey
+	t.macKey = testEncryptionKey
+	t.set(NewPath("paypal.com/personal/password"), NewPlaintext([]byte("secret"), Private))
+	t.set(NewPath("bitpay.com/work/password"), NewPlaintext([]byte("42 bitcoins"), Private))
+	return t
+}
+
+// checksum stores a valid checksum in t
+func checksum(t *Tree) {
+	t.set(NewPath("hush-tree-checksum"), NewPlaintext(t.Checksum(), Public))
+}
+
+func TestBech32RoundTrip(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	s, err := bech32Encode("age", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hrp, got, err := bech32Decode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hrp != "age" || !bytes.Equal(got, data) {
+		t.Errorf("round trip failed: %q %q", hrp, got)
+	}
+
+	typo := []byte(s)
+	typo[10] = 'q'
+	if typo[10] == s[10] {
+		typo[10] = 'p'
+	}
+	if _, _, err := bech32Decode(string(typo)); err == nil {
+		t.Errorf("typo not detected")
+	}
+}
+
+func TestEdwardsToMontgomery(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := edwardsToMontgomery(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := curve25519.X25519(edwardsPrivateToMontgomery(priv), curve25519.Basepoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expect) {
+		t.Errorf("public and private conversions disagree")
+	}
+}
+
+func TestRecipientSSH(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree := newTestTree()
+	err = tree.AddRecipient("alice", string(ssh.MarshalAuthorizedKey(sshPub)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum(tree)
+
+	locked := tree.Empty()
+	locked.encryptionKey = nil
+	locked.macKey = nil
+	for _, branch := range tree.branches {
+		locked.set(branch.path, branch.val)
+	}
+	err = locked.SetIdentity(pem.EncodeToMemory(block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(locked.encryptionKey, tree.encryptionKey) {
+		t.Errorf("wrong encryption key")
+	}
+}
+
+func TestRecipientRotation(t *testing.T) {
+	tree := newTestTree()
+	tree.passwordKey = testEncryptionKey
+	tree.wrapMaster(testEncryptionKey)
+	for _, name := range []string{"alice", "bob"} {
+		err := tree.AddPasswordRecipient(name, []byte(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree = tree.Encrypt()
+
+	err := tree.RemoveRecipient("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(tree.encryptionKey, testEncryptionKey) {
+		t.Errorf("keys weren't rotated")
+	}
+	if names := tree.Recipients(); len(names) != 1 || names[0] != "alice" {
+		t.Errorf("wrong recipients: %q", names)
+	}
+	encrypted := tree.Encrypt().Encode()
+	checksum(encrypted)
+	encrypted.encryptionKey = nil
+	encrypted.macKey = nil
+	encrypted.passwordKey = nil
+	if err := encrypted.SetPassphrase([]byte("bob")); err == nil {
+		t.Errorf("removed recipient can still unlock")
+	}
+	if err := encrypted.SetPassphrase([]byte("alice")); err != nil {
+		t.Fatalf("remaining recipient can't unlock: %s", err)
+	}
+	v, _ := encrypted.get(NewPath("paypal.com/personal/password"))
+	v, err = v.Plaintext(encrypted.encryptionKey)
+	if err != nil || string(v.plaintext) != "secret" {
+		t.Errorf("can't read leaf after rotation: %s", err)
+	}
+
+	// without the master password, keys can't be rotated
+	err = encrypted.RemoveRecipient("alice")
+	if err == nil || !strings.Contains(err.Error(), "master password") {
+		t.Errorf("want master password error, got %v", err)
+	}
+	if names := encrypted.Recipients(); len(names) != 1 {
+		t.Errorf("failed removal changed recipients: %q", names)
+	}
+}
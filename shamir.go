@@ -0,0 +1,170 @@
+package hush
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Shamir's secret sharing splits a secret into n shares, any k of
+// which can reconstruct it.  Fewer than k shares reveal nothing about
+// the secret.  Each byte of the secret is the constant term of a
+// random polynomial of degree k-1 over GF(256).  A share is the value
+// of every polynomial at the share's x coordinate.
+
+// gfMul multiplies two elements of GF(256) using the AES polynomial.
+func gfMul(a, b byte) byte {
+	var p byte
+	for i := 0; i < 8; i++ {
+		if b&1 == 1 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// gfInv returns the multiplicative inverse of a in GF(256).
+func gfInv(a byte) byte {
+	// a^254 == a^-1 since a^255 == 1
+	r := byte(1)
+	for i := 0; i < 254; i++ {
+		r = gfMul(r, a)
+	}
+	return r
+}
+
+// shamirSplit divides secret into n shares, any k of which are
+// needed to reconstruct it.  Each share is the x coordinate followed
+// by one y coordinate per byte of secret.
+func shamirSplit(secret []byte, n, k int) ([][]byte, error) {
+	if k < 2 || k > n || n > 255 {
+		return nil, errors.New("need 2 <= k <= n <= 255")
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1) // x coordinate. never 0
+	}
+
+	coefficients := make([]byte, k)
+	for j, s := range secret {
+		_, err := rand.Read(coefficients[1:])
+		if err != nil {
+			return nil, err
+		}
+		coefficients[0] = s
+		for _, share := range shares {
+			// evaluate polynomial with Horner's method
+			x, y := share[0], byte(0)
+			for c := k - 1; c >= 0; c-- {
+				y = gfMul(y, x) ^ coefficients[c]
+			}
+			share[j+1] = y
+		}
+	}
+	return shares, nil
+}
+
+// shamirCombine reconstructs a secret from shares created by
+// shamirSplit.  It can't tell whether enough shares were given; too few
+// shares produce garbage.
+func shamirCombine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("need at least 2 shares")
+	}
+	size := len(shares[0])
+	for i, share := range shares {
+		if len(share) != size || share[0] == 0 {
+			return nil, errors.New("malformed share")
+		}
+		for _, other := range shares[:i] {
+			if other[0] == share[0] {
+				return nil, errors.New("duplicate share")
+			}
+		}
+	}
+
+	// Lagrange interpolation at x = 0
+	secret := make([]byte, size-1)
+	for i, share := range shares {
+		basis := byte(1)
+		for j, other := range shares {
+			if i == j {
+				continue
+			}
+			basis = gfMul(basis, gfMul(other[0], gfInv(other[0]^share[0])))
+		}
+		for b := range secret {
+			secret[b] ^= gfMul(basis, share[b+1])
+		}
+	}
+	return secret, nil
+}
+
+// recoveryShare is one share of a tree's keys, in a form suitable for
+// writing down.  Its bytes are: the number of shares needed, the
+// Shamir share itself and a 4 byte checksum of everything before it.
+type recoveryShare []byte
+
+const recoveryShareSize = 1 + 1 + 64 + 4
+
+func newRecoveryShare(k int, share []byte) recoveryShare {
+	r := append([]byte{byte(k)}, share...)
+	sum := sha256.Sum256(r)
+	return append(r, sum[:4]...)
+}
+
+// parseRecoveryShare converts words back into a share, verifying its
+// checksum along the way.
+func parseRecoveryShare(words []string) (recoveryShare, error) {
+	if len(words) != recoveryShareSize {
+		return nil, fmt.Errorf("share should have %d words, got %d", recoveryShareSize, len(words))
+	}
+	r := make(recoveryShare, len(words))
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if len(word) < 4 {
+			return nil, fmt.Errorf("word %d is too short: %s", i+1, word)
+		}
+		b, ok := wordIndex[word[:4]]
+		if !ok || !strings.HasPrefix(wordlist[b], word) {
+			return nil, fmt.Errorf("word %d is not recognized: %s", i+1, word)
+		}
+		r[i] = b
+	}
+	n := len(r) - 4
+	sum := sha256.Sum256(r[:n])
+	if !bytes.Equal(sum[:4], r[n:]) {
+		return nil, errors.New("share checksum doesn't match. mistyped word?")
+	}
+	return r, nil
+}
+
+// needed returns the number of shares needed to reconstruct the keys
+func (r recoveryShare) needed() int {
+	return int(r[0])
+}
+
+// share returns the underlying Shamir share
+func (r recoveryShare) share() []byte {
+	return r[1 : len(r)-4]
+}
+
+// words returns the share as a list of words.
+func (r recoveryShare) words() []string {
+	words := make([]string, len(r))
+	for i, b := range r {
+		words[i] = wordlist[b]
+	}
+	return words
+}
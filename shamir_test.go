@@ -0,0 +1,54 @@
+package hush
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestShamirRoundTrip(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+	shares, err := shamirSplit(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subsets := [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}}
+	for _, subset := range subsets {
+		var some [][]byte
+		for _, i := range subset {
+			some = append(some, shares[i])
+		}
+		got, err := shamirCombine(some)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Errorf("shares %v: got %q", subset, got)
+		}
+	}
+
+	got, _ := shamirCombine(shares[:2])
+	if bytes.Equal(got, secret) {
+		t.Errorf("too few shares revealed the secret")
+	}
+}
+
+func TestRecoveryShareWords(t *testing.T) {
+	shares, err := shamirSplit(make([]byte, 64), 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := newRecoveryShare(2, shares[0]).words()
+	r, err := parseRecoveryShare(words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.needed() != 2 || !bytes.Equal(r.share(), shares[0]) {
+		t.Errorf("round trip failed")
+	}
+
+	words[7] = wordlist[(int(wordIndex[words[7][:4]])+1)%len(wordlist)]
+	if _, err := parseRecoveryShare(words); err == nil {
+		t.Errorf("mistyped word not detected")
+	}
+}
@@ -0,0 +1,281 @@
+package hush
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// A hush file may be signed so that readers know who wrote it.  The
+// tree's checksum only proves that the writer knew the MAC key, which
+// every recipient does.  A signature proves which writer it was.
+//
+// The writers a user trusts, called signers, are listed outside the
+// hush file, in the file named by HUSH_SIGNERS ($HOME/.hush-signers by
+// default).  Anyone who can write the hush file could change a list
+// kept inside it.  Each line of the list is an SSH ed25519 public key
+// in authorized_keys format whose comment is the signer's name.
+//
+// A signed file records the signer's public key and a signature of its
+// content.  Once a user trusts any signers, hush refuses to load files
+// which aren't signed by one of them, including files which are no
+// longer signed at all.
+
+// trustedSigner is a writer whose signatures the user accepts.
+type trustedSigner struct {
+	name string
+	key  ed25519.PublicKey
+}
+
+// SignersPath returns the filename of the user's list of trusted
+// signers, whether it exists or not.
+func SignersPath() (string, error) {
+	if filename := os.Getenv("HUSH_SIGNERS"); filename != "" {
+		return filename, nil
+	}
+	home, err := Home()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".hush-signers"), nil
+}
+
+// readSigners returns the user's trusted signers.  A missing list
+// trusts nobody.
+func readSigners() ([]trustedSigner, error) {
+	filename, err := SignersPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading signers")
+	}
+
+	var signers []trustedSigner
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, name, err := parseSignerKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %s", filename, n, err)
+		}
+		signers = append(signers, trustedSigner{name: name, key: key})
+	}
+	return signers, nil
+}
+
+// writeSigners replaces the user's list of trusted signers.
+func writeSigners(signers []trustedSigner) error {
+	filename, err := SignersPath()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, signer := range signers {
+		fmt.Fprintf(&buf, "%s %s\n", authorizedKey(signer.key), signer.name)
+	}
+	return ioutil.WriteFile(filename, buf.Bytes(), safePerm)
+}
+
+// AddSigner trusts the holder of an SSH ed25519 key to sign hush files.
+// An existing signer with the same name is replaced.
+func AddSigner(name, key string) error {
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("invalid signer name: %q", name)
+	}
+	public, _, err := parseSignerKey(key)
+	if err != nil {
+		return err
+	}
+	signers, err := readSigners()
+	if err != nil {
+		return err
+	}
+	kept := []trustedSigner{}
+	for _, signer := range signers {
+		if signer.name != name {
+			kept = append(kept, signer)
+		}
+	}
+	return writeSigners(append(kept, trustedSigner{name: name, key: public}))
+}
+
+// RemoveSigner stops trusting the named signer.
+func RemoveSigner(name string) error {
+	signers, err := readSigners()
+	if err != nil {
+		return err
+	}
+	kept := []trustedSigner{}
+	for _, signer := range signers {
+		if signer.name != name {
+			kept = append(kept, signer)
+		}
+	}
+	if len(kept) == len(signers) {
+		return fmt.Errorf("unknown signer: %s", name)
+	}
+	return writeSigners(kept)
+}
+
+// signerName returns the name under which key is trusted.  Returns
+// false if it isn't trusted.
+func signerName(signers []trustedSigner, key ed25519.PublicKey) (string, bool) {
+	for _, signer := range signers {
+		if signer.key.Equal(key) {
+			return signer.name, true
+		}
+	}
+	return "", false
+}
+
+// SetSigningKey sets the private key used to sign this tree when it's
+// saved.  key is an OpenSSH ed25519 private key.
+func (t *Tree) SetSigningKey(key []byte) error {
+	private, err := parseEd25519Key(key)
+	if err != nil {
+		return errors.Wrap(err, "signing key")
+	}
+	t.signingKey = private
+	return nil
+}
+
+// Signer returns the public key of the writer who last signed this
+// tree, in authorized_keys format.  If the tree isn't signed, returns
+// the empty string.
+func (t *Tree) Signer() string {
+	v, ok := t.get(NewPath("hush-tree-signer"))
+	if !ok {
+		return ""
+	}
+	v, err := v.Decode()
+	if err != nil {
+		return ""
+	}
+	return string(v.plaintext)
+}
+
+// Digest returns a cryptographic hash of this tree's content.  Unlike
+// Checksum, anyone can calculate it.  Each path and value is prefixed
+// with its length so that different trees can't hash the same bytes.
+func (t *Tree) Digest() []byte {
+	h := sha256.New()
+	field := func(s string) {
+		var n [binary.MaxVarintLen64]byte
+		h.Write(n[:binary.PutUvarint(n[:], uint64(len(s)))])
+		h.Write([]byte(s))
+	}
+	for _, branch := range t.branches {
+		if branch.path.IsChecksum() || branch.path.IsSignature() {
+			continue
+		}
+		field(string(branch.path))
+		field(branch.val.String())
+	}
+	return h.Sum(nil)
+}
+
+// sign returns the public key of t.signingKey and a signature of t's
+// content.  If there's no signing key, returns empty values, unless
+// the user trusts signers and so would refuse to load the result.
+func (t *Tree) sign(trusted []trustedSigner) (string, []byte, error) {
+	if t.signingKey == nil {
+		if len(trusted) > 0 {
+			return "", nil, errors.New("hush file must be signed. set HUSH_SIGNING_KEY")
+		}
+		return "", nil, nil
+	}
+
+	public := t.signingKey.Public().(ed25519.PublicKey)
+	if _, ok := signerName(trusted, public); !ok && len(trusted) > 0 {
+		return "", nil, errors.New("signing key does not belong to a trusted signer")
+	}
+	return authorizedKey(public), ed25519.Sign(t.signingKey, t.Digest()), nil
+}
+
+// VerifySignature confirms that this tree's signature matches its
+// content.  If the user trusts any signers, the tree must be signed
+// by one of them.
+func (t *Tree) VerifySignature(trusted []trustedSigner) error {
+	signer := t.Signer()
+	if signer == "" {
+		if len(trusted) > 0 {
+			filename, _ := SignersPath()
+			return fmt.Errorf("hush file isn't signed but %s trusts signers", filename)
+		}
+		return nil
+	}
+
+	key, _, err := parseSignerKey(signer)
+	if err != nil {
+		return errors.Wrap(err, "hush file signer")
+	}
+	v, ok := t.get(NewPath("hush-tree-signature"))
+	if !ok {
+		return errors.New("hush file has a signer but no signature")
+	}
+	v, err = v.Decode()
+	if err != nil {
+		return errors.Wrap(err, "decoding signature")
+	}
+	if !ed25519.Verify(key, t.Digest(), v.plaintext) {
+		return errors.New("signature doesn't match. file modified without hush command?")
+	}
+	if _, ok := signerName(trusted, key); !ok && len(trusted) > 0 {
+		return fmt.Errorf("hush file signed by untrusted key %s", fingerprint(key))
+	}
+	return nil
+}
+
+// parseSignerKey parses an SSH ed25519 public key in authorized_keys
+// format.  Also returns the key's comment.
+func parseSignerKey(key string) (ed25519.PublicKey, string, error) {
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return nil, "", errors.Wrap(err, "parsing signer key")
+	}
+	ck, ok := pub.(ssh.CryptoPublicKey)
+	if ok {
+		if k, ok := ck.CryptoPublicKey().(ed25519.PublicKey); ok {
+			return k, comment, nil
+		}
+	}
+	return nil, "", errors.New("signers must use ed25519 keys, not " + pub.Type())
+}
+
+// authorizedKey formats key like a line of authorized_keys, without a
+// comment.
+func authorizedKey(key ed25519.PublicKey) string {
+	pub, err := ssh.NewPublicKey(key)
+	if err != nil {
+		panic(err) // every ed25519 key is valid
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+}
+
+// fingerprint returns the SHA256 fingerprint of key, as ssh-keygen
+// shows it.
+func fingerprint(key ed25519.PublicKey) string {
+	pub, err := ssh.NewPublicKey(key)
+	if err != nil {
+		panic(err) // every ed25519 key is valid
+	}
+	return ssh.FingerprintSHA256(pub)
+}
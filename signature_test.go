@@ -0,0 +1,134 @@
+package hush
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// newSigner creates a signing key and trusts it under name.
+func newSigner(t *testing.T, name string) ed25519.PrivateKey {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddSigner(name, authorizedKey(public)); err != nil {
+		t.Fatal(err)
+	}
+	return private
+}
+
+func TestSignatureRoundTrip(t *testing.T) {
+	useTempHushFile(t)
+	tree := newTestTree()
+	tree.signingKey = newSigner(t, "alice")
+	if err := tree.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := LoadTree()
+	if err != nil {
+		t.Fatalf("loading signed tree: %s", err)
+	}
+	key, _, err := parseSignerKey(tree.Signer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers, err := readSigners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, _ := signerName(signers, key); name != "alice" {
+		t.Errorf("want signer alice, got %q", name)
+	}
+
+	// changing content without signing again breaks the signature
+	tree.set(NewPath("paypal.com/personal/password"), NewPlaintext([]byte("changed"), Private))
+	if err := tree.VerifySignature(signers); err == nil {
+		t.Errorf("tampered tree should fail verification")
+	}
+}
+
+func TestSignatureStripped(t *testing.T) {
+	filename := useTempHushFile(t)
+	tree := newTestTree()
+	tree.signingKey = newSigner(t, "alice")
+	if err := tree.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kept []string
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if !strings.HasPrefix(line, "hush-tree-sign") {
+			kept = append(kept, line)
+		}
+	}
+	err = ioutil.WriteFile(filename, []byte(strings.Join(kept, "")), safePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadTree(); err == nil {
+		t.Errorf("unsigned tree loaded despite trusted signers")
+	}
+}
+
+func TestSignatureUntrusted(t *testing.T) {
+	useTempHushFile(t)
+	tree := newTestTree()
+	tree.signingKey = newSigner(t, "mallory")
+	if err := tree.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	newSigner(t, "alice")
+	if err := RemoveSigner("mallory"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTree(); err == nil {
+		t.Errorf("tree signed by an untrusted key loaded")
+	}
+
+	// nor can an untrusted key sign
+	tree = newTestTree()
+	_, tree.signingKey, _ = ed25519.GenerateKey(rand.Reader)
+	if err := tree.Save(); err == nil {
+		t.Errorf("saved with an untrusted signing key")
+	}
+	tree.signingKey = nil
+	if err := tree.Save(); err == nil {
+		t.Errorf("saved without signing despite trusted signers")
+	}
+}
+
+func TestSignatureNotRequired(t *testing.T) {
+	useTempHushFile(t)
+	if err := newTestTree().Save(); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := LoadTree()
+	if err != nil {
+		t.Fatalf("unsigned tree without signers: %s", err)
+	}
+	if tree.Signer() != "" {
+		t.Errorf("want no signer, got %q", tree.Signer())
+	}
+}
+
+func TestDigestUnambiguous(t *testing.T) {
+	digest := func(path, value string) []byte {
+		tree := newTestTree().Empty()
+		tree.set(NewPath(path), NewPlaintext([]byte(value), Public))
+		return tree.Digest()
+	}
+	if bytes.Equal(digest("a/b", "cd"), digest("a/bc", "d")) {
+		t.Errorf("moving bytes from a value into its path kept the digest")
+	}
+}
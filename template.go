@@ -0,0 +1,116 @@
+package hush
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Templates are rendered with Go's text/template package.  They look
+// up values in the tree with functions, like {{ secret "a/b" }}, and
+// are given no other data.  Referring to a leaf which doesn't exist is
+// an error, so a typo never renders as an empty value.  Only the
+// leaves a template refers to are decrypted.
+//
+// When checking a template, paths are verified but nothing is
+// decrypted, so the tree needn't be unlocked.
+
+// templateLeaf is one leaf of a subtree, as seen by templates.  Name is
+// its path relative to the subtree.
+type templateLeaf struct {
+	Name  string
+	Path  string
+	tree  *Tree
+	check bool
+}
+
+// Value decrypts the leaf's value.
+func (l templateLeaf) Value() (string, error) {
+	if l.check {
+		return "", nil
+	}
+	return l.tree.plaintext(NewPath(l.Path))
+}
+
+// plaintext returns the decrypted value of leaf p.  Binary
+// attachments have no plaintext value.
+func (t *Tree) plaintext(p Path) (string, error) {
+	v, ok := t.get(p)
+	if !ok || !p.IsUserData() {
+		return "", fmt.Errorf("no such leaf: %s", p)
+	}
+	if t.IsAttachment(p) {
+		return "", attachmentError(p)
+	}
+	v, err := v.Plaintext(t.encryptionKey)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", p, err)
+	}
+	return string(v.plaintext), nil
+}
+
+// templateFuncs returns the functions available to templates rendered
+// against tree.  If check is true, values are never decrypted.
+func templateFuncs(tree *Tree, check bool) template.FuncMap {
+	return template.FuncMap{
+		"secret": func(s string) (string, error) {
+			p := NewPath(s)
+			if check {
+				if _, ok := tree.get(p); !ok || !p.IsUserData() {
+					return "", fmt.Errorf("no such leaf: %s", p)
+				}
+				if tree.IsAttachment(p) {
+					return "", attachmentError(p)
+				}
+				return "", nil
+			}
+			return tree.plaintext(p)
+		},
+		"subtree": func(s string) ([]templateLeaf, error) {
+			dir := NewPath(s)
+			tree.Sort()
+			var leaves []templateLeaf
+			for _, branch := range tree.branches {
+				p := branch.path
+				if p.IsUserData() && dir.HasDescendant(p) {
+					if tree.IsAttachment(p) {
+						return nil, attachmentError(p)
+					}
+					leaves = append(leaves, templateLeaf{
+						Name:  strings.TrimPrefix(string(p), string(dir)+"/"),
+						Path:  p.String(),
+						tree:  tree,
+						check: check,
+					})
+				}
+			}
+			if len(leaves) == 0 {
+				return nil, fmt.Errorf("no leaves beneath %s", dir)
+			}
+			return leaves, nil
+		},
+		"json": func(s string) (string, error) {
+			data, err := json.Marshal(s)
+			return string(data), err
+		},
+		"yaml": func(s string) (string, error) {
+			data, err := yaml.Marshal(s)
+			return strings.TrimSuffix(string(data), "\n"), err
+		},
+		"shell": func(s string) string {
+			return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+		},
+		"url": url.QueryEscape,
+	}
+}
+
+// parseTemplate reads the template in filename.
+func parseTemplate(tree *Tree, filename string, check bool) (*template.Template, error) {
+	t := template.New(filepath.Base(filename)).Funcs(templateFuncs(tree, check))
+	return t.ParseFiles(filename)
+}
@@ -0,0 +1,164 @@
+package hush
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// tty is the user's terminal in raw mode, for interactive commands.
+type tty struct {
+	file  *os.File
+	in    *bufio.Reader
+	state *terminal.State
+}
+
+// key identifies a key pressed on the terminal.  Printable characters
+// are reported as keyRune.
+type key int
+
+const (
+	keyRune key = iota
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyTab
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyCtrlC
+	keyCtrlD
+	keyCtrlU
+	keyUnknown
+)
+
+// isTerminal returns true if f is a terminal.
+func isTerminal(f *os.File) bool {
+	return terminal.IsTerminal(fd(f))
+}
+
+// fd returns f's file descriptor.  Unlike f.Fd, it leaves f in
+// non-blocking mode so that closing f interrupts pending reads.
+func fd(f *os.File) int {
+	var n int
+	if conn, err := f.SyscallConn(); err == nil {
+		conn.Control(func(d uintptr) { n = int(d) })
+	}
+	return n
+}
+
+// openTTY opens the user's terminal and puts it in raw mode.  Call
+// Close to restore the terminal.
+func openTTY() (*tty, error) {
+	f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	state, err := terminal.MakeRaw(fd(f))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &tty{file: f, in: bufio.NewReader(f), state: state}, nil
+}
+
+// Close restores the terminal to its original mode.
+func (t *tty) Close() error {
+	terminal.Restore(fd(t.file), t.state)
+	return t.file.Close()
+}
+
+// Write writes to the terminal.
+func (t *tty) Write(p []byte) (int, error) {
+	return t.file.Write(p)
+}
+
+// Printf writes formatted text to the terminal.  In raw mode, lines
+// must end with "\r\n".
+func (t *tty) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(t.file, format, args...)
+}
+
+// Size returns the width and height of the terminal.  Terminals which
+// don't know their size are assumed to be 80x24.
+func (t *tty) Size() (int, int) {
+	width, height, err := terminal.GetSize(fd(t.file))
+	if err != nil || width < 10 || height < 2 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// ReadKey waits for the user to press a key.  If the key is printable,
+// it's returned as keyRune along with the character.
+func (t *tty) ReadKey() (key, rune, error) {
+	r, _, err := t.in.ReadRune()
+	if err != nil {
+		return keyUnknown, 0, err
+	}
+	switch r {
+	case '\r', '\n':
+		return keyEnter, r, nil
+	case '\t':
+		return keyTab, r, nil
+	case 127, 8:
+		return keyBackspace, r, nil
+	case 3:
+		return keyCtrlC, r, nil
+	case 4:
+		return keyCtrlD, r, nil
+	case 21:
+		return keyCtrlU, r, nil
+	case 14: // ctrl-n
+		return keyDown, r, nil
+	case 16: // ctrl-p
+		return keyUp, r, nil
+	case 27:
+		return t.readEscape()
+	}
+	if r < 32 {
+		return keyUnknown, r, nil
+	}
+	return keyRune, r, nil
+}
+
+// readEscape decodes the rest of an escape sequence.  A lone escape
+// arrives without anything buffered behind it.
+func (t *tty) readEscape() (key, rune, error) {
+	if t.in.Buffered() == 0 {
+		return keyEscape, 27, nil
+	}
+	b, err := t.in.ReadByte()
+	if err != nil {
+		return keyUnknown, 0, err
+	}
+	if b != '[' && b != 'O' {
+		return keyUnknown, 0, nil
+	}
+
+	// read parameters up to the final byte
+	for {
+		b, err = t.in.ReadByte()
+		if err == io.EOF || (err == nil && b >= 0x40) {
+			break
+		}
+		if err != nil {
+			return keyUnknown, 0, err
+		}
+	}
+	switch b {
+	case 'A':
+		return keyUp, 0, nil
+	case 'B':
+		return keyDown, 0, nil
+	case 'C':
+		return keyRight, 0, nil
+	case 'D':
+		return keyLeft, 0, nil
+	}
+	return keyUnknown, 0, nil
+}
@@ -1,6 +1,7 @@
 package hush
 
 import (
+	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/sha256"
 	"fmt"
@@ -30,6 +31,9 @@ type Tree struct {
 
 	encryptionKey []byte
 	macKey        []byte
+	passwordKey   []byte // stretched master password, if known
+	keyfile       []byte
+	signingKey    ed25519.PrivateKey
 }
 
 const safePerm = 0600 // rw- --- ---
@@ -71,6 +75,14 @@ func LoadTree() (*Tree, error) {
 		return nil, errors.Wrap(err, "can't parse hush file")
 	}
 	tree := newT(keys)
+	signers, err := readSigners()
+	if err != nil {
+		return nil, err
+	}
+	err = tree.VerifySignature(signers)
+	if err != nil {
+		return nil, err
+	}
 	return tree, nil
 }
 
@@ -80,6 +92,7 @@ func newT(items yaml.MapSlice) *Tree {
 		index:    make(map[Path]int),
 	}
 	newT_(items, []string{}, t)
+	t.fixMetadataPrivacy()
 	return t
 }
 
@@ -148,13 +161,20 @@ func (t *Tree) Sort() {
 			break
 		}
 	}
+
+	// nothing is free anymore and swaps may have indexed deleted branches
+	t.free = nil
+	t.index = make(map[Path]int, len(t.branches))
+	for i, branch := range t.branches {
+		t.index[branch.path] = i
+	}
 }
 
 func (t *Tree) mapSlice() yaml.MapSlice {
 	var slice yaml.MapSlice
 	for _, branch := range t.branches {
-		if branch.path.IsChecksum() {
-			// skip checksum. it's appended by Save()
+		if branch.path.IsChecksum() || branch.path.IsSignature() {
+			// skip checksum and signature. they're appended by Save()
 			continue
 		}
 		crumbs := branch.path.AsCrumbs()
@@ -163,7 +183,7 @@ func (t *Tree) mapSlice() yaml.MapSlice {
 	return slice
 }
 
-func mapSlice_(slice yaml.MapSlice, path []string, value string) yaml.MapSlice {
+func mapSlice_(slice yaml.MapSlice, path []string, value interface{}) yaml.MapSlice {
 	if len(path) == 0 {
 		panic("path should never have 0 length")
 	}
@@ -196,7 +216,7 @@ func (t *Tree) Checksum() []byte {
 	}
 	mac := hmac.New(sha256.New, t.macKey)
 	for _, branch := range t.branches {
-		if branch.path.IsChecksum() {
+		if branch.path.IsChecksum() || branch.path.IsSignature() {
 			continue // don't checksum the checksum
 		}
 		mac.Write([]byte(branch.path))
@@ -207,39 +227,39 @@ func (t *Tree) Checksum() []byte {
 }
 
 // Filter returns a subtree whose branches all match the given
-// pattern.
-func (t *Tree) Filter(pattern string) *Tree {
+// pattern.  See compilePattern for the pattern syntax.
+func (t *Tree) Filter(pattern string) (*Tree, error) {
+	match, err := compilePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
 	keep := t.Empty()
 	for _, branch := range t.branches {
-		if matches(branch.path, pattern) {
+		if branch.path.IsMetadata() || branch.path.IsAttachment() {
+			continue
+		}
+		if match(branch.path) {
 			keep.set(branch.path, branch.val)
 		}
 	}
-	return keep
-}
 
-func isLowercase(s string) bool {
-	return s == strings.ToLower(s)
-}
-
-func matches(p Path, pattern string) bool {
-	ps := p.AsCrumbs()
-	patterns := strings.Split(pattern, "/")
-	if len(patterns) > len(ps) {
-		return false
-	}
-
-	ignoreCase := isLowercase(pattern)
-	for i, pattern := range patterns {
-		haystack := ps[i]
-		if ignoreCase {
-			haystack = strings.ToLower(haystack)
+	// retain metadata and attachments for the leaves we kept
+	for _, branch := range t.branches {
+		var owner Path
+		switch {
+		case branch.path.IsMetadata():
+			owner, _ = metadataOwner(branch.path)
+		case branch.path.IsAttachment():
+			owner = attachmentOwner(branch.path)
+		default:
+			continue
 		}
-		if !strings.Contains(haystack, pattern) {
-			return false
+		if _, ok := keep.get(owner); ok {
+			keep.set(branch.path, branch.val)
 		}
 	}
-	return true
+	return keep, nil
 }
 
 func (t *Tree) get(p Path) (*Value, bool) {
@@ -260,15 +280,22 @@ func (t *Tree) set(p Path, val *Value) {
 	}
 }
 
-// Delete removes a path and all its descendants from the tree.  Returns
-// the number of branches removed.
+// Delete removes a path and all its descendants from the tree, along
+// with their metadata and attachments.  Returns the number of branches
+// removed.
 func (t *Tree) Delete(paths ...Path) int {
 	n := 0
 	for _, p := range paths {
+		m := metadataRoot(p)
+		a := attachmentRoot(p)
 		for i, branch := range t.branches {
-			if p == branch.path || p.HasDescendant(branch.path) {
+			if branch.val == nil {
+				continue // already deleted
+			}
+			if p == branch.path || p.HasDescendant(branch.path) ||
+				m.HasDescendant(branch.path) || a.HasDescendant(branch.path) {
 				t.branches[i] = Branch{}
-				delete(t.index, p)
+				delete(t.index, branch.path)
 				if t.free == nil {
 					t.free = make(map[int]bool)
 				}
@@ -280,6 +307,77 @@ func (t *Tree) Delete(paths ...Path) int {
 	return n
 }
 
+// Rename moves the leaf at from, along with its metadata and
+// attachment, to to.  to must not already be a leaf or a subtree,
+// nor lie beneath a leaf.
+func (t *Tree) Rename(from, to Path) error {
+	v, ok := t.get(from)
+	if !ok {
+		return fmt.Errorf("no such leaf: %s", from)
+	}
+	if to == from {
+		return fmt.Errorf("can't rename %s to itself", from)
+	}
+	if from.HasDescendant(to) {
+		return fmt.Errorf("can't rename %s beneath itself", from)
+	}
+	if _, ok := t.get(to); ok {
+		return fmt.Errorf("leaf already exists: %s", to)
+	}
+	if leaf, ok := t.leafAbove(to); ok {
+		return fmt.Errorf("%s is a leaf", leaf)
+	}
+	for _, branch := range t.branches {
+		if to.HasDescendant(branch.path) {
+			return fmt.Errorf("%s is a subtree", to)
+		}
+	}
+	t.dropAttachment(to)
+	t.set(to, v)
+	for _, field := range metadataFields {
+		if m, ok := t.get(metadataPath(from, field)); ok {
+			t.set(metadataPath(to, field), m)
+		}
+	}
+	for n := 0; ; n++ {
+		c, ok := t.get(attachmentChunk(from, n))
+		if !ok {
+			break
+		}
+		t.set(attachmentChunk(to, n), c)
+	}
+	t.Delete(from)
+	return nil
+}
+
+// leafAbove returns the leaf which is an ancestor of p, if any.  Such
+// a leaf can't also be a subtree.
+func (t *Tree) leafAbove(p Path) (Path, bool) {
+	for strings.Contains(string(p), "/") {
+		p = p.Parent()
+		if _, ok := t.get(p); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Lock wipes this tree's keys from memory.  Plaintext values aren't
+// touched, so call Encrypt first.
+func (t *Tree) Lock() {
+	keys := [][]byte{t.encryptionKey, t.macKey, t.passwordKey, t.keyfile, t.signingKey}
+	for _, key := range keys {
+		for i := range key {
+			key[i] = 0
+		}
+	}
+	t.encryptionKey = nil
+	t.macKey = nil
+	t.passwordKey = nil
+	t.keyfile = nil
+	t.signingKey = nil
+}
+
 // Encrypt returns a copy of this tree with all leaves encrypted.
 func (tree *Tree) Encrypt() *Tree {
 	t := tree.Empty()
@@ -289,7 +387,7 @@ func (tree *Tree) Encrypt() *Tree {
 		if v == nil {
 			panic("trimming didn't remove any empty branch")
 		}
-		if p.IsPublic() { // don't encrypt public data
+		if p.IsPublic() || v.privacy == Public { // don't encrypt public data
 			t.set(p, v)
 			continue
 		}
@@ -297,6 +395,10 @@ func (tree *Tree) Encrypt() *Tree {
 			t.set(p, v)
 			continue
 		}
+		if p.IsRecipient() { // values use recipients' keys
+			t.set(p, v)
+			continue
+		}
 		t.set(p, v.Ciphertext(t.encryptionKey))
 	}
 	return t
@@ -311,18 +413,54 @@ func (tree *Tree) Encode() *Tree {
 	return t
 }
 
+// Clone returns a copy of this tree which can be changed without
+// changing the original.
+func (t *Tree) Clone() *Tree {
+	tree := t.Empty()
+	for _, branch := range t.branches {
+		if branch.val != nil {
+			tree.set(branch.path, branch.val)
+		}
+	}
+	return tree
+}
+
 // Empty returns a copy of this tree with all the keys and values
 // removed.  It retains any other data associated with this tree.
 func (t *Tree) Empty() *Tree {
 	tree := *t // shallow copy
 	tree.branches = make([]Branch, 0, len(t.branches))
 	tree.index = make(map[Path]int, len(t.branches))
+	tree.free = nil
 	return &tree
 }
 
 // SetPassphrase sets the password that's used for performing
-// encryption and decryption.
+// encryption and decryption.  The password may be either the master
+// password or that of a password recipient.
 func (t *Tree) SetPassphrase(password []byte) error {
+	err := t.unlockMaster(password)
+	if err != nil {
+		for _, name := range t.Recipients() {
+			if !t.IsPasswordRecipient(name) {
+				continue
+			}
+			if t.unlockRecipientPassword(name, password) == nil {
+				err = nil
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// now that we have a password, we can verify the checksum
+	return t.verifyChecksum()
+}
+
+// unlockMaster unwraps the tree's keys using the master password.
+func (t *Tree) unlockMaster(password []byte) error {
 	p := NewPath("hush-configuration/salt")
 	v, ok := t.get(p)
 	if !ok {
@@ -333,7 +471,7 @@ func (t *Tree) SetPassphrase(password []byte) error {
 		return errors.Wrap(err, "decoding salt")
 	}
 	salt := v.plaintext
-	pwKey := stretchPassword(password, salt)
+	pwKey := stretchPassword(password, t.keyfile, salt)
 
 	p = NewPath("hush-configuration/encryption-key")
 	v, ok = t.get(p)
@@ -356,13 +494,77 @@ func (t *Tree) SetPassphrase(password []byte) error {
 		return fmt.Errorf("incorrect password or corrupted mac key")
 	}
 	t.macKey = v.plaintext
+	t.passwordKey = pwKey
+	return nil
+}
 
-	// now that we have a password, we can verify the checksum
+// SetKeyfile sets the key file content that's combined with the
+// password when unlocking the tree.
+func (t *Tree) SetKeyfile(keyfile []byte) {
+	t.keyfile = keyfile
+}
+
+// Factors reports whether unlocking the tree with its master password
+// needs a password, a key file or both.
+func (t *Tree) Factors() (password, keyfile bool) {
+	v, ok := t.get(NewPath("hush-configuration/factors"))
+	if !ok {
+		return true, false
+	}
+	v, err := v.Decode()
+	if err != nil {
+		return true, false
+	}
+	for _, factor := range strings.Split(string(v.plaintext), "+") {
+		switch factor {
+		case "password":
+			password = true
+		case "keyfile":
+			keyfile = true
+		}
+	}
+	return password, keyfile
+}
+
+// SetMasterPassword changes the master password.  Either password or
+// keyfile may be nil, but not both.  If both are given, both are
+// needed to unlock the tree.  The tree must already be unlocked.
+func (t *Tree) SetMasterPassword(password, keyfile []byte) error {
+	var factors []string
+	if password != nil {
+		factors = append(factors, "password")
+	}
+	if keyfile != nil {
+		factors = append(factors, "keyfile")
+	}
+	if len(factors) == 0 {
+		return errors.New("need a password or a key file")
+	}
+
+	salt, err := randomBytes(16) // double the RFC8018 minimum
+	if err != nil {
+		return err
+	}
+	p := NewPath("hush-configuration/factors")
+	if password != nil && keyfile == nil {
+		t.Delete(p) // the default
+	} else {
+		t.set(p, NewPlaintext([]byte(strings.Join(factors, "+")), Public))
+	}
+	t.set(NewPath("hush-configuration/salt"), NewPlaintext(salt, Public))
+	t.wrapMaster(stretchPassword(password, keyfile, salt))
+	t.keyfile = keyfile
+	return nil
+}
+
+// verifyChecksum confirms that the tree hasn't been modified by
+// anyone who doesn't have the MAC key.
+func (t *Tree) verifyChecksum() error {
 	got, ok := t.get(NewPath("hush-tree-checksum"))
 	if !ok {
 		return errors.New("hush file has no checksum")
 	}
-	got, err = got.Decode()
+	got, err := got.Decode()
 	if err != nil {
 		return errors.Wrap(err, "decoding checksum")
 	}
@@ -381,7 +583,7 @@ func (tree *Tree) Decrypt() *Tree {
 	for _, branch := range tree.branches {
 		p := branch.path
 		v := branch.val
-		if p.IsPublic() { // don't decrypt public data
+		if p.IsPublic() || v.privacy == Public { // don't decrypt public data
 			t.set(p, v)
 			continue
 		}
@@ -393,6 +595,10 @@ func (tree *Tree) Decrypt() *Tree {
 			t.set(p, v)
 			continue
 		}
+		if p.IsRecipient() { // values use recipients' keys
+			t.set(p, v)
+			continue
+		}
 		if p.IsChecksum() { // not encrypted at all
 			t.set(p, v)
 			continue
@@ -408,9 +614,48 @@ func (tree *Tree) Decrypt() *Tree {
 
 // Print displays a tree for human consumption.
 func (tree *Tree) Print(w io.Writer) error {
+	return tree.print(w, false)
+}
+
+// PrintLong is like Print but also displays each leaf's metadata.
+func (tree *Tree) PrintLong(w io.Writer) error {
+	return tree.print(w, true)
+}
+
+func (tree *Tree) print(w io.Writer, long bool) error {
 	tree.Sort()
-	tree = tree.Decrypt()
-	slice := tree.mapSlice()
+
+	// attachments are summarized, so don't bother decrypting them
+	attached := make(map[Path]bool)
+	content := tree.Empty()
+	for _, branch := range tree.branches {
+		if branch.path.IsAttachment() {
+			attached[attachmentOwner(branch.path)] = true
+		} else {
+			content.set(branch.path, branch.val)
+		}
+	}
+	tree = content.Decrypt()
+
+	var slice yaml.MapSlice
+	for _, branch := range tree.branches {
+		p := branch.path
+		if p.IsChecksum() || p.IsSignature() || p.IsMetadata() {
+			continue
+		}
+		var value interface{} = branch.val.String()
+		if attached[p] {
+			size, _ := tree.metadataValue(metadataPath(p, "size"))
+			value = attachmentSummary(size)
+		}
+		if long {
+			if meta := tree.metadataSlice(p); meta != nil {
+				item := yaml.MapItem{Key: "value", Value: value}
+				value = append(yaml.MapSlice{item}, meta...)
+			}
+		}
+		slice = mapSlice_(slice, p.AsCrumbs(), value)
+	}
 	data, err := yaml.Marshal(slice)
 	if err != nil {
 		return errors.Wrap(err, "printing tree")
@@ -430,6 +675,14 @@ func (tree *Tree) Save() error {
 	if err != nil {
 		return errors.Wrap(err, "saving tree")
 	}
+	signers, err := readSigners()
+	if err != nil {
+		return errors.Wrap(err, "saving tree")
+	}
+	signer, signature, err := tree.sign(signers)
+	if err != nil {
+		return errors.Wrap(err, "saving tree")
+	}
 
 	// where does the saved data eventually belong?
 	hushPath, err := HushPath()
@@ -454,6 +707,13 @@ func (tree *Tree) Save() error {
 	io.WriteString(file, "hush-tree-checksum: ")
 	io.WriteString(file, NewPlaintext(tree.Checksum(), Public).Encode().String())
 	io.WriteString(file, "\n")
+	if signature != nil {
+		io.WriteString(file, "hush-tree-signature: ")
+		io.WriteString(file, NewPlaintext(signature, Public).Encode().String())
+		io.WriteString(file, "\nhush-tree-signer: ")
+		io.WriteString(file, NewPlaintext([]byte(signer), Public).Encode().String())
+		io.WriteString(file, "\n")
+	}
 	file.Close()
 	if err != nil {
 		return errors.Wrap(err, "saving tree")
@@ -496,8 +756,14 @@ func rename(oldpath, newpath string) error {
 }
 
 // stretchPassword converts a password and a salt into a
-// cryptographically secure key.
-func stretchPassword(password, salt []byte) []byte {
+// cryptographically secure key.  If keyfile is not nil, it's combined
+// with the password so that both are needed to derive the key.
+func stretchPassword(password, keyfile, salt []byte) []byte {
+	if keyfile != nil {
+		mac := hmac.New(sha256.New, keyfile)
+		mac.Write(password)
+		password = mac.Sum(nil)
+	}
 	pwKey := pbkdf2.Key(
 		password, salt,
 		2<<15, // iteration count (about 80ms on modern server)
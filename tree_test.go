@@ -0,0 +1,86 @@
+package hush
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setenv sets an environment variable for the rest of the test.
+func setenv(t *testing.T, name, value string) {
+	old, ok := os.LookupEnv(name)
+	os.Setenv(name, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+// useTempHushFile points HUSH_FILE at a file in a temporary directory
+// for the rest of the test.  The user trusts no signers.
+func useTempHushFile(t *testing.T) string {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "hush")
+	setenv(t, "HUSH_FILE", filename)
+	setenv(t, "HUSH_SIGNERS", filepath.Join(dir, "signers"))
+	return filename
+}
+
+func TestTreeSortAfterDelete(t *testing.T) {
+	tree := newTestTree()
+	a := NewPath("paypal.com/personal/password")
+	b := NewPath("bitpay.com/work/password")
+	c := NewPath("example.com/password")
+
+	tree.Delete(a)
+	tree.Sort()
+	tree.set(c, NewPlaintext([]byte("new"), Private))
+	tree.Sort()
+
+	if _, ok := tree.get(a); ok {
+		t.Errorf("%s should have been deleted", a)
+	}
+	for _, p := range []Path{b, c} {
+		v, ok := tree.get(p)
+		if !ok {
+			t.Errorf("%s is missing", p)
+			continue
+		}
+		if v == nil {
+			t.Errorf("%s has no value", p)
+		}
+	}
+	if len(tree.branches) != 2 {
+		t.Errorf("want 2 branches, got %d", len(tree.branches))
+	}
+}
+
+func TestTreeRenameInvalid(t *testing.T) {
+	tree := newTestTree()
+	from := NewPath("paypal.com/personal/password")
+	for _, to := range []string{
+		"paypal.com/personal/password",     // itself
+		"paypal.com/personal/password/old", // beneath itself
+		"bitpay.com/work/password",         // existing leaf
+		"bitpay.com/work/password/paypal",  // beneath a leaf
+		"bitpay.com/work",                  // subtree
+	} {
+		if err := tree.Rename(from, NewPath(to)); err == nil {
+			t.Errorf("renaming to %s should fail", to)
+		}
+	}
+	if _, ok := tree.get(from); !ok {
+		t.Errorf("%s should still exist", from)
+	}
+
+	to := NewPath("paypal.com/password")
+	if err := tree.Rename(from, to); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tree.get(to); !ok {
+		t.Errorf("%s should exist", to)
+	}
+}
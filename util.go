@@ -1,13 +1,19 @@
 package hush
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 
+	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
@@ -19,14 +25,12 @@ import (
 // By default, the prompt is displayed on w and the password is read,
 // without echo, from the terminal.
 func AskPassword(w io.Writer, prompt string) ([]byte, error) {
-	tty, err := os.Open("/dev/tty")
-	if err != nil {
-		return nil, err
-	}
-
 	if askpass := os.Getenv("HUSH_ASKPASS"); askpass != "" {
 		cmd := exec.Command(askpass, prompt)
-		cmd.Stdin = tty
+		if tty, err := os.Open("/dev/tty"); err == nil {
+			defer tty.Close()
+			cmd.Stdin = tty // if there's a terminal, askpass may use it
+		}
 		cmd.Stderr = os.Stderr
 		password, err := cmd.Output()
 		if err != nil {
@@ -35,12 +39,41 @@ func AskPassword(w io.Writer, prompt string) ([]byte, error) {
 		return password, nil
 	}
 
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
 	io.WriteString(w, prompt+": ")
 	password, err := terminal.ReadPassword(int(tty.Fd()))
 	io.WriteString(w, "\n")
 	return password, err
 }
 
+// parseEd25519Key parses an OpenSSH ed25519 private key.  If the key
+// is protected by a passphrase, the user is prompted for it.
+func parseEd25519Key(data []byte) (ed25519.PrivateKey, error) {
+	key, err := ssh.ParseRawPrivateKey(data)
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		var passphrase []byte
+		passphrase, err = AskPassword(os.Stderr, "Key passphrase")
+		if err != nil {
+			return nil, err
+		}
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(data, passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return k, nil
+	case *ed25519.PrivateKey:
+		return *k, nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %T", key)
+}
+
 // Home returns the user's home directory.
 func Home() (string, error) {
 	home := os.Getenv("HOME")
@@ -73,3 +106,60 @@ func HushPath() (string, error) {
 	}
 	return filename, err
 }
+
+// askNewPassword prompts the user to create a password, asking twice
+// to make sure it was typed correctly.
+func askNewPassword(w io.Writer) ([]byte, error) {
+	password, err := AskPassword(w, "Password")
+	if err != nil {
+		return nil, err
+	}
+	verify, err := AskPassword(w, "Verify password")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(password, verify) {
+		return nil, errors.New("Passwords don't match")
+	}
+	return password, nil
+}
+
+// keyfilePath is the key file named by the --keyfile option, if any
+var keyfilePath string
+
+// KeyfilePath returns the filename of the key file given on the
+// command line or in HUSH_KEYFILE.  If there's none, returns the empty
+// string.
+func KeyfilePath() string {
+	if keyfilePath != "" {
+		return keyfilePath
+	}
+	return os.Getenv("HUSH_KEYFILE")
+}
+
+// readKeyfile returns the content of a key file.  If create is true
+// and the file doesn't exist, it's created with random content.
+func readKeyfile(filename string, create bool) ([]byte, error) {
+	keyfile, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) && create {
+		keyfile, err = randomBytes(32)
+		if err != nil {
+			return nil, err
+		}
+		err = ioutil.WriteFile(filename, keyfile, safePerm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(keyfile) == 0 {
+		return nil, errors.New("key file is empty: " + filename)
+	}
+	return keyfile, nil
+}
+
+// randomBytes returns n cryptographically secure random bytes.
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	return b, err
+}
@@ -0,0 +1,48 @@
+package hush
+
+// wordlist maps each byte value to an English word.  Words are
+// between four and six letters long and no two words share their first
+// four letters, so a word can be recognized from its prefix.
+var wordlist = [256]string{
+	"acid", "acorn", "actor", "agent", "alarm", "album", "alley", "alpha",
+	"amber", "ankle", "apple", "arena", "armor", "arrow", "atlas", "attic",
+	"audio", "autumn", "avenue", "badge", "bagel", "baker", "banjo", "barn",
+	"basil", "beard", "beast", "berry", "bison", "blade", "blaze", "blond",
+	"bloom", "boat", "bonus", "boost", "brain", "brass", "brick", "bride",
+	"brook", "bucket", "buddy", "bugle", "cabin", "cable", "camel", "candy",
+	"canoe", "cargo", "carpet", "carrot", "cedar", "chalk", "chapel", "cheese",
+	"cherry", "chief", "child", "choir", "cigar", "cinema", "civic", "clam",
+	"clay", "cloak", "clock", "cloud", "coach", "cobra", "cocoa", "coral",
+	"corn", "couch", "cousin", "coyote", "crane", "crater", "crayon", "crown",
+	"cube", "cycle", "daisy", "dance", "denim", "desert", "diary", "dingo",
+	"disco", "domain", "donkey", "dragon", "dream", "drum", "duck", "eagle",
+	"easel", "echo", "elder", "ember", "engine", "ethics", "fabric", "fancy",
+	"farm", "ferry", "fiddle", "finch", "flame", "flute", "foam", "fossil",
+	"frost", "fudge", "garden", "garlic", "geyser", "ghost", "giant", "globe",
+	"glove", "goat", "gospel", "gravel", "gulf", "hammer", "harbor", "hawk",
+	"hazel", "helmet", "hero", "hippo", "honey", "hotel", "hunter", "index",
+	"iron", "island", "jacket", "jaguar", "jelly", "jockey", "judge", "jungle",
+	"kayak", "kernel", "kidney", "king", "kiosk", "koala", "label", "lagoon",
+	"lamp", "laser", "lemon", "lens", "lily", "lizard", "locket", "lunar",
+	"magnet", "mango", "marble", "meadow", "melon", "mirror", "mitten", "monkey",
+	"mosaic", "motor", "mural", "napkin", "nectar", "nest", "noodle", "north",
+	"oasis", "ocean", "onion", "opera", "orange", "orchid", "otter", "oyster",
+	"palace", "panda", "pasta", "peach", "pebble", "pepper", "piano", "pickle",
+	"pillow", "pilot", "pirate", "plum", "pocket", "pony", "poppy", "potato",
+	"puzzle", "python", "quartz", "rabbit", "radar", "raven", "razor", "relic",
+	"ribbon", "river", "robin", "rodeo", "rose", "saddle", "salmon", "sandal",
+	"scarf", "scout", "seal", "shark", "shell", "sketch", "skunk", "snail",
+	"sofa", "spider", "spoon", "stable", "statue", "stone", "summit", "sunset",
+	"syrup", "table", "tango", "temple", "tennis", "tiger", "toast", "tomato",
+	"torch", "tulip", "tunnel", "turtle", "valley", "velvet", "wagon", "walnut",
+	"water", "whale", "willow", "winter", "wizard", "wolf", "yogurt", "zebra",
+}
+
+// wordIndex maps each word's four letter prefix back to its byte value
+var wordIndex = make(map[string]byte, len(wordlist))
+
+func init() {
+	for i, word := range wordlist {
+		wordIndex[word[:4]] = byte(i)
+	}
+}